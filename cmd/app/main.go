@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
@@ -57,11 +58,71 @@ func runCLI(ctx context.Context, args []string, logger *log.Logger, cfg config.C
 	cmd := strings.ToLower(args[1])
 	switch cmd {
 	case "install":
-		return pkgmgr.RunInstall(ctx, logger, cfg)
+		opts, err := parseInstallOptions(cmd, args[2:])
+		if err != nil {
+			return err
+		}
+		return pkgmgr.RunInstall(ctx, logger, cfg, opts)
 	case "update":
-		return pkgmgr.RunUpdate(ctx, logger, cfg)
+		opts, err := parseInstallOptions(cmd, args[2:])
+		if err != nil {
+			return err
+		}
+		return pkgmgr.RunUpdate(ctx, logger, cfg, opts)
+	case "require":
+		opts, err := parseRequireOptions(cmd, args[2:])
+		if err != nil {
+			return err
+		}
+		return pkgmgr.RunRequire(ctx, logger, cfg, opts)
+	case "remove":
+		opts, err := parseRemoveOptions(cmd, args[2:])
+		if err != nil {
+			return err
+		}
+		return pkgmgr.RunRemove(ctx, logger, cfg, opts)
+	case "show", "info":
+		opts, err := parseShowOptions(cmd, args[2:])
+		if err != nil {
+			return err
+		}
+		return pkgmgr.RunShow(ctx, logger, cfg, opts)
+	case "outdated":
+		opts, err := parseOutdatedOptions(cmd, args[2:])
+		if err != nil {
+			return err
+		}
+		return pkgmgr.RunOutdated(ctx, logger, cfg, opts)
+	case "why", "depends":
+		opts, err := parseWhyOptions(cmd, args[2:], 1)
+		if err != nil {
+			return err
+		}
+		return pkgmgr.RunWhy(ctx, logger, cfg, opts)
+	case "why-not", "prohibits":
+		opts, err := parseWhyOptions(cmd, args[2:], 2)
+		if err != nil {
+			return err
+		}
+		return pkgmgr.RunWhyNot(ctx, logger, cfg, opts)
+	case "status":
+		opts, err := parseStatusOptions(cmd, args[2:])
+		if err != nil {
+			return err
+		}
+		return pkgmgr.RunStatus(ctx, logger, cfg, opts)
 	case "dump-autoload":
 		return pkgmgr.RunDumpAutoload(ctx, logger, cfg)
+	case "check-platform-reqs":
+		opts, err := parseCheckPlatformOptions(cmd, args[2:])
+		if err != nil {
+			return err
+		}
+		return pkgmgr.RunCheckPlatformReqs(ctx, logger, cfg, opts)
+	case "clear-cache", "clearcache":
+		return pkgmgr.RunClearCache(ctx, logger, cfg)
+	case "cache":
+		return runCacheCommand(ctx, args, logger, cfg)
 	case "help", "-h", "--help":
 		printUsage(logger)
 		return nil
@@ -71,6 +132,224 @@ func runCLI(ctx context.Context, args []string, logger *log.Logger, cfg config.C
 	}
 }
 
+// parseInstallOptions parses the flags shared by install and update
+func parseInstallOptions(cmd string, args []string) (pkgmgr.InstallOptions, error) {
+	var opts pkgmgr.InstallOptions
+
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	addInstallFlags(fs, &opts)
+
+	if err := fs.Parse(args); err != nil {
+		return opts, fmt.Errorf("parse %s flags: %w", cmd, err)
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected arguments for %s: %v", cmd, fs.Args())
+	}
+	return opts, checkInstallFlags(cmd, opts)
+}
+
+// addInstallFlags registers the install/update flags on fs
+func addInstallFlags(fs *flag.FlagSet, opts *pkgmgr.InstallOptions) {
+	fs.BoolVar(&opts.IgnorePlatformReqs, "ignore-platform-reqs", false, "ignore all php, ext-* and lib-* requirements")
+	fs.Func("ignore-platform-req", "ignore a specific platform requirement (e.g. ext-foo or ext-*), repeatable", func(v string) error {
+		opts.IgnorePlatformReq = append(opts.IgnorePlatformReq, v)
+		return nil
+	})
+	fs.BoolVar(&opts.PreferSource, "prefer-source", false, "install packages from their git source")
+	fs.BoolVar(&opts.PreferDist, "prefer-dist", false, "install packages from their dist archive")
+}
+
+// checkInstallFlags rejects contradictory install/update flags
+func checkInstallFlags(cmd string, opts pkgmgr.InstallOptions) error {
+	if opts.PreferSource && opts.PreferDist {
+		return fmt.Errorf("%s: --prefer-source and --prefer-dist cannot be combined", cmd)
+	}
+	return nil
+}
+
+// parseRequireOptions parses require's package arguments and flags, which
+// may be given in any order
+func parseRequireOptions(cmd string, args []string) (pkgmgr.RequireOptions, error) {
+	var opts pkgmgr.RequireOptions
+
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.BoolVar(&opts.Dev, "dev", false, "add the packages to require-dev")
+	addInstallFlags(fs, &opts.InstallOptions)
+
+	packages, err := parseInterspersed(fs, args)
+	if err != nil {
+		return opts, fmt.Errorf("parse %s flags: %w", cmd, err)
+	}
+	if len(packages) == 0 {
+		return opts, fmt.Errorf("%s needs at least one package, e.g. vendor/name:^1.0", cmd)
+	}
+	opts.Packages = packages
+	return opts, checkInstallFlags(cmd, opts.InstallOptions)
+}
+
+// parseRemoveOptions parses remove's package arguments and flags, which may
+// be given in any order
+func parseRemoveOptions(cmd string, args []string) (pkgmgr.RemoveOptions, error) {
+	var opts pkgmgr.RemoveOptions
+
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.BoolVar(&opts.Dev, "dev", false, "remove the packages from require-dev")
+	addInstallFlags(fs, &opts.InstallOptions)
+
+	packages, err := parseInterspersed(fs, args)
+	if err != nil {
+		return opts, fmt.Errorf("parse %s flags: %w", cmd, err)
+	}
+	if len(packages) == 0 {
+		return opts, fmt.Errorf("%s needs at least one package, e.g. vendor/name", cmd)
+	}
+	opts.Packages = packages
+	return opts, checkInstallFlags(cmd, opts.InstallOptions)
+}
+
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments, returning the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// parseCheckPlatformOptions parses the flags of check-platform-reqs
+func parseCheckPlatformOptions(cmd string, args []string) (pkgmgr.CheckPlatformOptions, error) {
+	var opts pkgmgr.CheckPlatformOptions
+
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.BoolVar(&opts.Lock, "lock", false, "check composer.lock instead of the installed packages")
+	fs.StringVar(&opts.Format, "format", "text", "output format: text or json")
+
+	if err := fs.Parse(args); err != nil {
+		return opts, fmt.Errorf("parse %s flags: %w", cmd, err)
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected arguments for %s: %v", cmd, fs.Args())
+	}
+	return opts, nil
+}
+
+// parseShowOptions parses show's optional package argument and flags, which
+// may be given in any order
+func parseShowOptions(cmd string, args []string) (pkgmgr.ShowOptions, error) {
+	var opts pkgmgr.ShowOptions
+
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.BoolVar(&opts.All, "all", false, "list the versions available in the repositories")
+	fs.BoolVar(&opts.Installed, "installed", false, "show installed packages (default)")
+	fs.BoolVar(&opts.Locked, "locked", false, "show packages from composer.lock")
+	fs.BoolVar(&opts.Tree, "tree", false, "render the dependency tree")
+	fs.BoolVar(&opts.Self, "self", false, "show the root package")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return opts, fmt.Errorf("parse %s flags: %w", cmd, err)
+	}
+	if len(positional) > 1 {
+		return opts, fmt.Errorf("unexpected arguments for %s: %v", cmd, positional[1:])
+	}
+	if len(positional) == 1 {
+		opts.Package = positional[0]
+	}
+	return opts, nil
+}
+
+// parseOutdatedOptions parses the flags of outdated
+func parseOutdatedOptions(cmd string, args []string) (pkgmgr.OutdatedOptions, error) {
+	var opts pkgmgr.OutdatedOptions
+
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.BoolVar(&opts.Direct, "direct", false, "only packages required by composer.json")
+	fs.BoolVar(&opts.MinorOnly, "minor-only", false, "only consider semver-compatible updates")
+	fs.BoolVar(&opts.Strict, "strict", false, "exit with an error when any package is outdated")
+	fs.StringVar(&opts.Format, "format", "text", "output format: text or json")
+	fs.BoolVar(&opts.IgnorePlatformReqs, "ignore-platform-reqs", false, "ignore all php, ext-* and lib-* requirements")
+
+	if err := fs.Parse(args); err != nil {
+		return opts, fmt.Errorf("parse %s flags: %w", cmd, err)
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected arguments for %s: %v", cmd, fs.Args())
+	}
+	return opts, nil
+}
+
+// parseWhyOptions parses the package (and for why-not version) arguments and
+// flags of why and why-not, which may be given in any order
+func parseWhyOptions(cmd string, args []string, want int) (pkgmgr.WhyOptions, error) {
+	var opts pkgmgr.WhyOptions
+
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.BoolVar(&opts.Recursive, "recursive", false, "show every chain of dependents up to the root package")
+	fs.BoolVar(&opts.Recursive, "r", false, "shorthand for --recursive")
+	fs.BoolVar(&opts.Tree, "tree", false, "show the dependents as an inverted tree")
+	fs.BoolVar(&opts.Tree, "t", false, "shorthand for --tree")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return opts, fmt.Errorf("parse %s flags: %w", cmd, err)
+	}
+	if len(positional) != want {
+		if want == 2 {
+			return opts, fmt.Errorf("%s needs a package and a version, e.g. vendor/name 3.0", cmd)
+		}
+		return opts, fmt.Errorf("%s needs exactly one package, e.g. vendor/name", cmd)
+	}
+	opts.Package = positional[0]
+	if want == 2 {
+		opts.Version = positional[1]
+	}
+	return opts, nil
+}
+
+// parseStatusOptions parses the flags of status
+func parseStatusOptions(cmd string, args []string) (pkgmgr.StatusOptions, error) {
+	var opts pkgmgr.StatusOptions
+
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.BoolVar(&opts.Verbose, "verbose", false, "list the changed files of each package")
+	fs.BoolVar(&opts.Verbose, "v", false, "shorthand for --verbose")
+
+	if err := fs.Parse(args); err != nil {
+		return opts, fmt.Errorf("parse %s flags: %w", cmd, err)
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected arguments for %s: %v", cmd, fs.Args())
+	}
+	return opts, nil
+}
+
+func runCacheCommand(ctx context.Context, args []string, logger *log.Logger, cfg config.Config) error {
+	if len(args) < 3 {
+		printUsage(logger)
+		return fmt.Errorf("no cache subcommand specified")
+	}
+
+	sub := strings.ToLower(args[2])
+	switch sub {
+	case "gc":
+		return pkgmgr.RunCacheGC(ctx, logger, cfg)
+	case "verify":
+		return pkgmgr.RunCacheVerify(ctx, logger, cfg)
+	case "clear":
+		return pkgmgr.RunClearCache(ctx, logger, cfg)
+	default:
+		printUsage(logger)
+		return fmt.Errorf("unknown cache subcommand: %s", sub)
+	}
+}
+
 // printUsage prints help text to stdout intentionally bypassing the logger
 // to avoid timestamp/JSON formatting that would make the output less readable
 func printUsage(logger *log.Logger) {
@@ -78,6 +357,33 @@ func printUsage(logger *log.Logger) {
 
 Usage:
   phpResolver install        Install project dependencies
-  phpResolver update         Update dependencies to their newest versions  
-  phpResolver dump-autoload  Dump the autoloader`)
+  phpResolver update         Update dependencies to their newest versions and write composer.lock
+  phpResolver require PKG... Add packages (vendor/name[:constraint]) to composer.json
+                             and install them (--dev for require-dev)
+  phpResolver remove PKG...  Remove packages from composer.json and uninstall those
+                             no longer required (--dev for require-dev)
+  phpResolver show [PKG]     List installed packages, or show one in detail
+                             (--all, --installed, --locked, --tree, --self)
+  phpResolver outdated       List locked packages with newer versions (--direct, --minor-only,
+                             --strict to fail when outdated, --format=json)
+  phpResolver why PKG        Show which packages require PKG (--recursive for every chain
+                             from the root, --tree for an inverted tree)
+  phpResolver why-not PKG VERSION
+                             Show which installed packages prevent PKG at VERSION
+                             (--recursive, --tree)
+  phpResolver status         List installed packages with local changes (-v lists the files)
+  phpResolver dump-autoload  Dump the autoloader
+  phpResolver check-platform-reqs
+                             Check installed packages' php, ext-* and lib-* requirements
+                             against the running PHP (--lock, --format=json)
+  phpResolver clear-cache    Remove all cached package archives and store entries
+  phpResolver cache gc       Evict cache and store entries by age and total size
+  phpResolver cache verify   Re-hash cached archives and remove corrupt ones and
+                             modified store entries
+
+Install/update/require/remove options:
+  --ignore-platform-reqs     Ignore all php, ext-* and lib-* requirements
+  --ignore-platform-req=REQ  Ignore a specific platform requirement (repeatable, ext-* wildcards allowed)
+  --prefer-source            Clone packages from their git source (overrides config.preferred-install)
+  --prefer-dist              Install packages from their dist archive (overrides config.preferred-install)`)
 }
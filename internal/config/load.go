@@ -24,7 +24,23 @@ func defaultConfig() Config {
 			FilePath:    "",
 		},
 		Pkgmgr: PkgmgrConfig{
-			MaxConcurrentDownloads: 5, // Default: 5
+			MaxConcurrentDownloads:   5,   // Default: 5
+			MaxConcurrentExtractions: 4,   // Default: 4
+			CacheTTLDays:             180, // Matches Composer's cache-files-ttl of six months
+			CacheMaxSizeMB:           300, // Matches Composer's cache-files-maxsize
+			LockTimeoutSeconds:       300, // Default: 5 minutes
+
+			HTTPTimeoutSeconds:        30,
+			HTTPConnectTimeoutSeconds: 10,
+
+			PHPBinary:   "php",
+			GitBinary:   "git",
+			InstallMode: InstallModeExtract,
+
+			ExtractMaxTotalSizeMB:      1024,
+			ExtractMaxFileSizeMB:       256,
+			ExtractMaxEntries:          100000,
+			ExtractMaxCompressionRatio: 200,
 		},
 	}
 }
@@ -102,5 +118,68 @@ func validate(cfg Config) error {
 			cfg.Pkgmgr.MaxConcurrentDownloads, ErrInvalidMaxConcurrentDownloads)
 	}
 
+	if !ValidMaxConcurrentExtractions(cfg.Pkgmgr.MaxConcurrentExtractions) {
+		return fmt.Errorf("invalid pkgmgr.max_concurrent_extractions %d (must be 1-50): %w",
+			cfg.Pkgmgr.MaxConcurrentExtractions, ErrInvalidMaxConcurrentExtractions)
+	}
+
+	if !ValidCacheTTLDays(cfg.Pkgmgr.CacheTTLDays) {
+		return fmt.Errorf("invalid pkgmgr.cache_ttl_days %d (must be >= 0): %w",
+			cfg.Pkgmgr.CacheTTLDays, ErrInvalidCacheTTL)
+	}
+
+	if !ValidCacheMaxSizeMB(cfg.Pkgmgr.CacheMaxSizeMB) {
+		return fmt.Errorf("invalid pkgmgr.cache_max_size_mb %d (must be >= 0): %w",
+			cfg.Pkgmgr.CacheMaxSizeMB, ErrInvalidCacheMaxSize)
+	}
+
+	if !ValidLockTimeoutSeconds(cfg.Pkgmgr.LockTimeoutSeconds) {
+		return fmt.Errorf("invalid pkgmgr.lock_timeout_seconds %d (must be 1-3600): %w",
+			cfg.Pkgmgr.LockTimeoutSeconds, ErrInvalidLockTimeout)
+	}
+
+	if !ValidHTTPTimeoutSeconds(cfg.Pkgmgr.HTTPTimeoutSeconds) {
+		return fmt.Errorf("invalid pkgmgr.http_timeout_seconds %d (must be 1-3600): %w",
+			cfg.Pkgmgr.HTTPTimeoutSeconds, ErrInvalidHTTPTimeout)
+	}
+
+	if !ValidHTTPTimeoutSeconds(cfg.Pkgmgr.HTTPConnectTimeoutSeconds) {
+		return fmt.Errorf("invalid pkgmgr.http_connect_timeout_seconds %d (must be 1-3600): %w",
+			cfg.Pkgmgr.HTTPConnectTimeoutSeconds, ErrInvalidHTTPTimeout)
+	}
+
+	if cfg.Pkgmgr.PHPBinary == "" {
+		return fmt.Errorf("invalid pkgmgr.php_binary (must not be empty): %w", ErrInvalidPHPBinary)
+	}
+
+	if cfg.Pkgmgr.GitBinary == "" {
+		return fmt.Errorf("invalid pkgmgr.git_binary (must not be empty): %w", ErrInvalidGitBinary)
+	}
+
+	if !IsValidInstallMode(cfg.Pkgmgr.InstallMode) {
+		return fmt.Errorf("invalid pkgmgr.install_mode %q (must be one of: %v): %w",
+			cfg.Pkgmgr.InstallMode, ValidInstallModes(), ErrInvalidInstallMode)
+	}
+
+	if !ValidExtractLimit(cfg.Pkgmgr.ExtractMaxTotalSizeMB) {
+		return fmt.Errorf("invalid pkgmgr.extract_max_total_size_mb %d (must be >= 0): %w",
+			cfg.Pkgmgr.ExtractMaxTotalSizeMB, ErrInvalidExtractLimit)
+	}
+
+	if !ValidExtractLimit(cfg.Pkgmgr.ExtractMaxFileSizeMB) {
+		return fmt.Errorf("invalid pkgmgr.extract_max_file_size_mb %d (must be >= 0): %w",
+			cfg.Pkgmgr.ExtractMaxFileSizeMB, ErrInvalidExtractLimit)
+	}
+
+	if !ValidExtractLimit(cfg.Pkgmgr.ExtractMaxEntries) {
+		return fmt.Errorf("invalid pkgmgr.extract_max_entries %d (must be >= 0): %w",
+			cfg.Pkgmgr.ExtractMaxEntries, ErrInvalidExtractLimit)
+	}
+
+	if !ValidExtractLimit(cfg.Pkgmgr.ExtractMaxCompressionRatio) {
+		return fmt.Errorf("invalid pkgmgr.extract_max_compression_ratio %d (must be >= 0): %w",
+			cfg.Pkgmgr.ExtractMaxCompressionRatio, ErrInvalidExtractLimit)
+	}
+
 	return nil
 }
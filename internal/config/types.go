@@ -24,6 +24,14 @@ const (
 	LogFormatLogfmt LogFormat = "logfmt"
 )
 
+// InstallMode is how packages are put into vendor/
+type InstallMode string
+
+const (
+	InstallModeExtract InstallMode = "extract" // unpack every archive into vendor/
+	InstallModeLink    InstallMode = "link"    // unpack once into a shared store, then reflink, hard link or copy
+)
+
 type LogConfig struct {
 	Level       LogLevel  `yaml:"level"`
 	Format      LogFormat `yaml:"format"`
@@ -33,7 +41,28 @@ type LogConfig struct {
 }
 
 type PkgmgrConfig struct {
-	MaxConcurrentDownloads int `yaml:"max_concurrent_downloads"` // Default: 5
+	MaxConcurrentDownloads   int `yaml:"max_concurrent_downloads"`   // Default: 5
+	MaxConcurrentExtractions int `yaml:"max_concurrent_extractions"` // Default: 4, packages unpacked into vendor/ at once
+	CacheTTLDays             int `yaml:"cache_ttl_days"`             // Default: 180, 0 disables age-based eviction
+	CacheMaxSizeMB           int `yaml:"cache_max_size_mb"`          // Default: 300, 0 disables size-based eviction
+	LockTimeoutSeconds       int `yaml:"lock_timeout_seconds"`       // Default: 300
+
+	// HTTP settings; proxies are taken from HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	HTTPTimeoutSeconds        int    `yaml:"http_timeout_seconds"`         // Default: 30, whole request including body
+	HTTPConnectTimeoutSeconds int    `yaml:"http_connect_timeout_seconds"` // Default: 10
+	CAFile                    string `yaml:"cafile"`                       // Extra CA bundle, composer.json config.cafile takes precedence
+	CAPath                    string `yaml:"capath"`                       // Directory of extra CA certificates
+
+	PHPBinary   string      `yaml:"php_binary"`   // Default: "php", used to detect platform packages
+	GitBinary   string      `yaml:"git_binary"`   // Default: "git", used for source installs
+	InstallMode InstallMode `yaml:"install_mode"` // Default: "extract"; "link" shares files between projects via ~/.phpResolver/store
+
+	// Limits applied to every dist archive before and while it is extracted;
+	// 0 disables a limit
+	ExtractMaxTotalSizeMB      int `yaml:"extract_max_total_size_mb"`     // Default: 1024, uncompressed size of one archive
+	ExtractMaxFileSizeMB       int `yaml:"extract_max_file_size_mb"`      // Default: 256, uncompressed size of one entry
+	ExtractMaxEntries          int `yaml:"extract_max_entries"`           // Default: 100000
+	ExtractMaxCompressionRatio int `yaml:"extract_max_compression_ratio"` // Default: 200, per entry larger than 1 MB
 }
 
 type Config struct {
@@ -47,9 +76,18 @@ type LoggerHandle struct {
 }
 
 var (
-	ErrInvalidLogLevel               = errors.New("invalid log level")
-	ErrInvalidLogFormat              = errors.New("invalid log format")
-	ErrInvalidMaxConcurrentDownloads = errors.New("invalid max concurrent downloads")
+	ErrInvalidLogLevel                 = errors.New("invalid log level")
+	ErrInvalidLogFormat                = errors.New("invalid log format")
+	ErrInvalidMaxConcurrentDownloads   = errors.New("invalid max concurrent downloads")
+	ErrInvalidMaxConcurrentExtractions = errors.New("invalid max concurrent extractions")
+	ErrInvalidCacheTTL                 = errors.New("invalid cache ttl")
+	ErrInvalidCacheMaxSize             = errors.New("invalid cache max size")
+	ErrInvalidLockTimeout              = errors.New("invalid lock timeout")
+	ErrInvalidHTTPTimeout              = errors.New("invalid http timeout")
+	ErrInvalidPHPBinary                = errors.New("invalid php binary")
+	ErrInvalidGitBinary                = errors.New("invalid git binary")
+	ErrInvalidExtractLimit             = errors.New("invalid extract limit")
+	ErrInvalidInstallMode              = errors.New("invalid install mode")
 )
 
 // Validation helpers - single source of truth
@@ -79,6 +117,43 @@ func IsValidLogFormat(format LogFormat) bool {
 	}
 }
 
+func ValidInstallModes() []InstallMode {
+	return []InstallMode{InstallModeExtract, InstallModeLink}
+}
+
+func IsValidInstallMode(mode InstallMode) bool {
+	switch mode {
+	case InstallModeExtract, InstallModeLink:
+		return true
+	default:
+		return false
+	}
+}
+
 func ValidMaxConcurrentDownloads(n int) bool {
 	return n >= 1 && n <= 50 // Min 1, max 50 to prevent abuse
 }
+
+func ValidMaxConcurrentExtractions(n int) bool {
+	return n >= 1 && n <= 50 // Extraction is disk-bound; more workers than this only add contention
+}
+
+func ValidCacheTTLDays(days int) bool {
+	return days >= 0
+}
+
+func ValidCacheMaxSizeMB(mb int) bool {
+	return mb >= 0
+}
+
+func ValidExtractLimit(n int) bool {
+	return n >= 0
+}
+
+func ValidLockTimeoutSeconds(seconds int) bool {
+	return seconds >= 1 && seconds <= 3600 // Waiting longer than an hour means something is stuck
+}
+
+func ValidHTTPTimeoutSeconds(seconds int) bool {
+	return seconds >= 1 && seconds <= 3600
+}
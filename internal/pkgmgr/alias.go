@@ -0,0 +1,149 @@
+package pkgmgr
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var inlineAliasRE = regexp.MustCompile(`(?i)^([^,\s#]+)(?:#\S+)?\s+as\s+([^,\s]+)$`)
+
+// inlineAlias is a root requirement such as "dev-fix as 2.3.0": Version is
+// the version actually installed, Alias the version it stands in for
+type inlineAlias struct {
+	Package string
+	Version string
+	Alias   string
+}
+
+// lockAlias is an entry of composer.lock's "aliases" section
+type lockAlias struct {
+	Package         string `json:"package"`
+	Version         string `json:"version"`
+	Alias           string `json:"alias"`
+	AliasNormalized string `json:"alias_normalized"`
+}
+
+// splitInlineAlias splits "dev-fix as 2.3.0" into its version and alias
+func splitInlineAlias(constraint string) (version, alias string, ok bool) {
+	m := inlineAliasRE.FindStringSubmatch(strings.TrimSpace(constraint))
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// rootInlineAliases extracts inline aliases from the root requirements,
+// keyed by lowercase package name
+func rootInlineAliases(require map[string]string) map[string]inlineAlias {
+	aliases := make(map[string]inlineAlias)
+	for name, constraint := range require {
+		version, alias, ok := splitInlineAlias(stabilityFlagRE.ReplaceAllString(constraint, ""))
+		if !ok {
+			continue
+		}
+		name = strings.ToLower(name)
+		aliases[name] = inlineAlias{Package: name, Version: version, Alias: alias}
+	}
+	return aliases
+}
+
+// branchAlias returns the extra.branch-alias target for a dev version, e.g.
+// "1.5.x-dev" for dev-main when the package declares {"dev-main": "1.5.x-dev"}.
+// Like Composer, only aliases to numbered -dev branches are honored.
+func branchAlias(version string, extra json.RawMessage) (string, bool) {
+	lower := strings.ToLower(version)
+	if len(extra) == 0 || !(strings.HasPrefix(lower, "dev-") || strings.HasSuffix(lower, "-dev")) {
+		return "", false
+	}
+
+	var decoded struct {
+		BranchAlias map[string]string `json:"branch-alias"`
+	}
+	if err := json.Unmarshal(extra, &decoded); err != nil {
+		return "", false // extra is free-form; anything unexpected means no alias
+	}
+
+	for source, target := range decoded.BranchAlias {
+		if !strings.EqualFold(source, version) || !strings.HasSuffix(strings.ToLower(target), "-dev") {
+			continue
+		}
+		parsed, err := parseVersion(target)
+		if err != nil || parsed.isBranch() {
+			continue
+		}
+		return target, true
+	}
+	return "", false
+}
+
+// highestVersion returns the newest of a package's version and its aliases,
+// which is what the package competes with when candidates are ordered
+func highestVersion(pkg Package) string {
+	highest := pkg.Version
+	for _, alias := range pkg.Aliases {
+		if compareVersions(alias, highest) > 0 {
+			highest = alias
+		}
+	}
+	return highest
+}
+
+// satisfiedBy reports whether pkg matches constraint through its own version
+// or any of its aliases
+func satisfiedBy(pkg Package, constraint string) bool {
+	if versionSatisfies(pkg.Version, constraint) {
+		return true
+	}
+	for _, alias := range pkg.Aliases {
+		if versionSatisfies(alias, constraint) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyInlineAlias returns versions with alias attached to the aliased
+// version, re-sorted newest first
+func applyInlineAlias(versions []Package, alias inlineAlias) []Package {
+	target := normalizeVersion(alias.Version)
+
+	out := make([]Package, len(versions))
+	copy(out, versions)
+	for i := range out {
+		if normalizeVersion(out[i].Version) == target {
+			out[i].Aliases = append(append([]string(nil), out[i].Aliases...), alias.Alias)
+		}
+	}
+	sortVersionsNewestFirst(out)
+	return out
+}
+
+func sortVersionsNewestFirst(versions []Package) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return compareVersions(highestVersion(versions[i]), highestVersion(versions[j])) > 0
+	})
+}
+
+// lockAliases builds composer.lock's "aliases" section from the root inline
+// aliases of the packages that were actually selected. Branch aliases are not
+// listed there; they are recorded in each package's extra.branch-alias.
+func lockAliases(require map[string]string, packages []Package) []lockAlias {
+	inline := rootInlineAliases(require)
+
+	out := []lockAlias{}
+	for _, pkg := range packages {
+		alias, ok := inline[pkg.Name]
+		if !ok || normalizeVersion(pkg.Version) != normalizeVersion(alias.Version) {
+			continue
+		}
+		out = append(out, lockAlias{
+			Package:         pkg.Name,
+			Version:         normalizeVersion(pkg.Version),
+			Alias:           alias.Alias,
+			AliasNormalized: normalizeVersion(alias.Alias),
+		})
+	}
+	return out
+}
@@ -0,0 +1,215 @@
+package pkgmgr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const authFileName = "auth.json"
+
+// AuthConfig holds repository credentials in Composer's auth.json format.
+// All maps are keyed by host name (optionally with port).
+type AuthConfig struct {
+	HTTPBasic     map[string]HTTPBasicAuth `json:"http-basic,omitempty"`
+	Bearer        map[string]string        `json:"bearer,omitempty"`
+	GitLabToken   map[string]GitLabToken   `json:"gitlab-token,omitempty"`
+	GitLabOAuth   map[string]string        `json:"gitlab-oauth,omitempty"`
+	GitHubOAuth   map[string]string        `json:"github-oauth,omitempty"`
+	CustomHeaders map[string][]string      `json:"custom-headers,omitempty"`
+}
+
+type HTTPBasicAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// GitLabToken is either a bare private token or a username/token pair
+// (the latter is sent as basic auth, e.g. for deploy tokens)
+type GitLabToken struct {
+	Username string `json:"username,omitempty"`
+	Token    string `json:"token"`
+}
+
+func (t *GitLabToken) UnmarshalJSON(data []byte) error {
+	var token string
+	if err := json.Unmarshal(data, &token); err == nil {
+		*t = GitLabToken{Token: token}
+		return nil
+	}
+
+	type plain GitLabToken
+	var obj plain
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("gitlab-token must be a string or {username, token} object")
+	}
+	*t = GitLabToken(obj)
+	return nil
+}
+
+// githubCredentialHosts are hosts that serve GitHub API and archive downloads
+// and share the credentials configured for github.com
+var githubCredentialHosts = map[string]string{
+	"api.github.com":      "github.com",
+	"codeload.github.com": "github.com",
+}
+
+// LoadAuthConfig merges credentials from ~/.phpResolver/auth.json, the project's
+// auth.json and the COMPOSER_AUTH environment variable, later sources
+// overriding earlier ones per host.
+func LoadAuthConfig(projectDir string) (*AuthConfig, error) {
+	auth := &AuthConfig{}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		if err := mergeAuthFile(auth, filepath.Join(home, ".phpResolver", authFileName)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mergeAuthFile(auth, filepath.Join(projectDir, authFileName)); err != nil {
+		return nil, err
+	}
+
+	if env := os.Getenv("COMPOSER_AUTH"); env != "" {
+		var fromEnv AuthConfig
+		if err := json.Unmarshal([]byte(env), &fromEnv); err != nil {
+			return nil, fmt.Errorf("parse COMPOSER_AUTH: %w", err)
+		}
+		auth.merge(fromEnv)
+	}
+
+	return auth, nil
+}
+
+func mergeAuthFile(auth *AuthConfig, path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+
+	var parsed AuthConfig
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	auth.merge(parsed)
+	return nil
+}
+
+func (a *AuthConfig) merge(other AuthConfig) {
+	a.HTTPBasic = mergeHostMap(a.HTTPBasic, other.HTTPBasic)
+	a.Bearer = mergeHostMap(a.Bearer, other.Bearer)
+	a.GitLabToken = mergeHostMap(a.GitLabToken, other.GitLabToken)
+	a.GitLabOAuth = mergeHostMap(a.GitLabOAuth, other.GitLabOAuth)
+	a.GitHubOAuth = mergeHostMap(a.GitHubOAuth, other.GitHubOAuth)
+	a.CustomHeaders = mergeHostMap(a.CustomHeaders, other.CustomHeaders)
+}
+
+func mergeHostMap[V any](dst, src map[string]V) map[string]V {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]V, len(src))
+	}
+	for host, v := range src {
+		dst[strings.ToLower(host)] = v
+	}
+	return dst
+}
+
+// lookupHost finds the entry for u's host, preferring an exact host:port
+// match over a bare host name match
+func lookupHost[V any](m map[string]V, u *url.URL) (V, bool) {
+	for _, host := range credentialHostCandidates(u) {
+		if v, ok := m[host]; ok {
+			return v, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+func credentialHostCandidates(u *url.URL) []string {
+	host := strings.ToLower(u.Host)
+	hostname := strings.ToLower(u.Hostname())
+
+	candidates := []string{host}
+	if hostname != host {
+		candidates = append(candidates, hostname)
+	}
+	if alias, ok := githubCredentialHosts[hostname]; ok {
+		candidates = append(candidates, alias)
+	}
+	return candidates
+}
+
+// apply sets the credentials configured for req's host on req. It must only
+// be called on a request owned by the caller (see authTransport).
+func (a *AuthConfig) apply(req *http.Request) {
+	if a == nil {
+		return
+	}
+
+	if headers, ok := lookupHost(a.CustomHeaders, req.URL); ok {
+		for _, header := range headers {
+			name, value, found := strings.Cut(header, ":")
+			if found {
+				req.Header.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+			}
+		}
+	}
+
+	// Only one Authorization scheme can be sent; the most explicit one wins
+	if basic, ok := lookupHost(a.HTTPBasic, req.URL); ok {
+		req.SetBasicAuth(basic.Username, basic.Password)
+		return
+	}
+	if token, ok := lookupHost(a.Bearer, req.URL); ok {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return
+	}
+	if token, ok := lookupHost(a.GitLabToken, req.URL); ok {
+		if token.Username != "" {
+			req.SetBasicAuth(token.Username, token.Token)
+		} else {
+			req.Header.Set("PRIVATE-TOKEN", token.Token)
+		}
+		return
+	}
+	if token, ok := lookupHost(a.GitLabOAuth, req.URL); ok {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return
+	}
+	if token, ok := lookupHost(a.GitHubOAuth, req.URL); ok {
+		req.Header.Set("Authorization", "token "+token)
+	}
+}
+
+// authTransport injects per-host credentials into every outgoing request,
+// including each hop of a redirect chain. Credentials are added to a clone
+// inside the transport rather than to the caller's request, so http.Client
+// never copies them onto a redirect: each hop only ever carries the
+// credentials configured for its own host.
+type authTransport struct {
+	base http.RoundTripper
+	auth *AuthConfig
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	authed := req.Clone(req.Context())
+	t.auth.apply(authed)
+	return t.base.RoundTrip(authed)
+}
+
+// authError wraps 401/403 responses with a hint about where credentials go
+func authError(u *url.URL, status string) error {
+	return fmt.Errorf("%s returned %s: missing or rejected credentials for %s (configure auth.json or COMPOSER_AUTH)", u.Redacted(), status, u.Host)
+}
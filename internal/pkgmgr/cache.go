@@ -0,0 +1,417 @@
+package pkgmgr
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/julian-richter/PhpResolver/internal/config"
+)
+
+// staleTempAge is how old an abandoned download temp file must be before gc removes it
+const staleTempAge = time.Hour
+
+// cacheTempRE matches the temp files os.CreateTemp makes for an archive
+// ("<name>.tmp<digits>") and its checksum ("<name>.zip.checksum.tmp<digits>")
+var cacheTempRE = regexp.MustCompile(`^(.+)\.tmp\d+$`)
+
+// cacheEntry is a single cached dist archive together with its recorded
+// checksum, or an unpacked entry of the package store
+type cacheEntry struct {
+	Path     string // the archive, or the store entry's directory
+	Store    bool
+	Size     int64 // archive and checksum file, or store files and their record, combined
+	LastUsed time.Time
+}
+
+// evict removes the entry under its lock
+func (e cacheEntry) evict(ctx context.Context, cfg config.Config, logger *log.Logger) error {
+	if e.Store {
+		return evictStoreEntry(ctx, e.Path, cfg, logger)
+	}
+	return evictCacheEntry(ctx, e.Path, cfg, logger)
+}
+
+// listAllEntries returns the download cache's archives and the store's entries
+func listAllEntries(ctx context.Context, cacheDir, storeDir string) ([]cacheEntry, error) {
+	entries, err := listCacheEntries(ctx, cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	storeEntries, err := listStoreEntries(ctx, storeDir)
+	if err != nil {
+		return nil, err
+	}
+	return append(entries, storeEntries...), nil
+}
+
+// CacheDir returns the shared download cache directory, creating it if needed
+func CacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("get user home dir: %w", err)
+	}
+	cacheDir := filepath.Join(home, ".phpResolver", "cache")
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return "", fmt.Errorf("create cache dir: %w", err)
+	}
+	return cacheDir, nil
+}
+
+// RunClearCache removes every entry from the download cache and the package
+// store. Entries are removed under their lock so downloads and installs in
+// other processes are not disturbed.
+func RunClearCache(ctx context.Context, logger *log.Logger, cfg config.Config) error {
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return err
+	}
+	storeDir, err := StoreDir()
+	if err != nil {
+		return err
+	}
+
+	entries, err := listAllEntries(ctx, cacheDir, storeDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := entry.evict(ctx, cfg, logger); err != nil {
+			return fmt.Errorf("remove cache entry %s: %w", entry.Path, err)
+		}
+	}
+
+	removeStaleTempFiles(ctx, cacheDir, cfg, logger)
+	removeStaleStoreTemps(storeDir, logger)
+	removeEmptyDirs(cacheDir)
+	removeEmptyDirs(storeDir)
+
+	logger.Info("Cache cleared", "cache_dir", cacheDir, "store_dir", storeDir, "removed", len(entries))
+	return nil
+}
+
+// RunCacheGC evicts cache and store entries that have not been used within
+// the configured TTL, then evicts least recently used entries until both
+// together fit the configured maximum size. Abandoned temp files from
+// interrupted downloads and store entries are removed too.
+func RunCacheGC(ctx context.Context, logger *log.Logger, cfg config.Config) error {
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return err
+	}
+	storeDir, err := StoreDir()
+	if err != nil {
+		return err
+	}
+
+	removeStaleTempFiles(ctx, cacheDir, cfg, logger)
+	removeStaleStoreTemps(storeDir, logger)
+
+	entries, err := listAllEntries(ctx, cacheDir, storeDir)
+	if err != nil {
+		return err
+	}
+
+	// Oldest first, so size-based eviction drops least recently used entries
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+
+	var totalSize int64
+	for _, entry := range entries {
+		totalSize += entry.Size
+	}
+
+	var evicted int
+	var freed int64
+	var kept []cacheEntry
+
+	if cfg.Pkgmgr.CacheTTLDays > 0 {
+		cutoff := time.Now().Add(-time.Duration(cfg.Pkgmgr.CacheTTLDays) * 24 * time.Hour)
+		for _, entry := range entries {
+			if entry.LastUsed.Before(cutoff) {
+				if err := entry.evict(ctx, cfg, logger); err != nil {
+					logger.Warn("Failed to evict cache entry", "path", entry.Path, "error", err)
+					kept = append(kept, entry)
+					continue
+				}
+				logger.Debug("Evicted expired cache entry", "path", entry.Path, "last_used", entry.LastUsed)
+				evicted++
+				freed += entry.Size
+				totalSize -= entry.Size
+				continue
+			}
+			kept = append(kept, entry)
+		}
+	} else {
+		kept = entries
+	}
+
+	if cfg.Pkgmgr.CacheMaxSizeMB > 0 {
+		maxSize := int64(cfg.Pkgmgr.CacheMaxSizeMB) * 1024 * 1024
+		for _, entry := range kept {
+			if totalSize <= maxSize {
+				break
+			}
+			if err := entry.evict(ctx, cfg, logger); err != nil {
+				logger.Warn("Failed to evict cache entry", "path", entry.Path, "error", err)
+				continue
+			}
+			logger.Debug("Evicted cache entry to reduce size", "path", entry.Path, "size", entry.Size)
+			evicted++
+			freed += entry.Size
+			totalSize -= entry.Size
+		}
+	}
+
+	removeEmptyDirs(cacheDir)
+	removeEmptyDirs(storeDir)
+
+	logger.Info("Cache garbage collection complete", "evicted", evicted, "freed_bytes", freed, "remaining_bytes", totalSize)
+	return nil
+}
+
+// RunCacheVerify re-hashes every cached archive against its recorded checksum
+// and removes entries that are corrupt or have no checksum to verify against.
+// Store entries whose files changed since they were unpacked are removed too.
+func RunCacheVerify(ctx context.Context, logger *log.Logger, cfg config.Config) error {
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return err
+	}
+	storeDir, err := StoreDir()
+	if err != nil {
+		return err
+	}
+
+	entries, err := listAllEntries(ctx, cacheDir, storeDir)
+	if err != nil {
+		return err
+	}
+
+	var removed int
+	for _, entry := range entries {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		verify := verifyAndEvictCacheEntry
+		if entry.Store {
+			verify = verifyAndEvictStoreEntry
+		}
+		invalid, err := verify(ctx, entry.Path, cfg, logger)
+		if err != nil {
+			return fmt.Errorf("verify cache entry %s: %w", entry.Path, err)
+		}
+		if invalid {
+			removed++
+		}
+	}
+
+	removeEmptyDirs(cacheDir)
+	removeEmptyDirs(storeDir)
+
+	logger.Info("Cache verification complete", "checked", len(entries), "removed", removed)
+	return nil
+}
+
+// verifyAndEvictCacheEntry verifies a cache entry under its exclusive lock and
+// removes it if invalid, reporting whether it was removed
+func verifyAndEvictCacheEntry(ctx context.Context, archivePath string, cfg config.Config, logger *log.Logger) (bool, error) {
+	lock, err := acquireFileLock(ctx, cacheLockPath(archivePath), true, lockTimeout(cfg), logger)
+	if err != nil {
+		return false, err
+	}
+
+	// The entry may have been evicted by another process while we waited
+	if _, err := os.Stat(archivePath); os.IsNotExist(err) {
+		return false, lock.ReleaseAndRemove()
+	}
+
+	if err := verifyCacheEntry(archivePath); err != nil {
+		logger.Warn("Removing invalid cache entry", "path", archivePath, "error", err)
+		if err := removeCacheEntry(archivePath); err != nil {
+			lock.Release()
+			return false, fmt.Errorf("remove invalid cache entry: %w", err)
+		}
+		return true, lock.ReleaseAndRemove()
+	}
+
+	logger.Debug("Cache entry verified", "path", archivePath)
+	return false, lock.Release()
+}
+
+// evictCacheEntry removes a cache entry and its lock file while holding the
+// entry's exclusive lock
+func evictCacheEntry(ctx context.Context, archivePath string, cfg config.Config, logger *log.Logger) error {
+	lock, err := acquireFileLock(ctx, cacheLockPath(archivePath), true, lockTimeout(cfg), logger)
+	if err != nil {
+		return err
+	}
+	if err := removeCacheEntry(archivePath); err != nil {
+		lock.Release()
+		return err
+	}
+	return lock.ReleaseAndRemove()
+}
+
+// verifyCacheEntry re-hashes a cached archive and compares it to its recorded checksum
+func verifyCacheEntry(archivePath string) error {
+	recorded, err := readRecordedChecksum(archivePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no recorded checksum")
+		}
+		return fmt.Errorf("read recorded checksum: %w", err)
+	}
+
+	hasher, err := newHasher(recorded.Algo)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("open archive: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(hasher, file); err != nil {
+		return fmt.Errorf("hash archive: %w", err)
+	}
+
+	actual := hex.EncodeToString(hasher.Sum(nil))
+	if !recorded.Matches(actual) {
+		return fmt.Errorf("checksum mismatch: recorded %s, got %s:%s", recorded, recorded.Algo, actual)
+	}
+	return nil
+}
+
+// listCacheEntries walks the cache and returns every committed archive
+func listCacheEntries(ctx context.Context, cacheDir string) ([]cacheEntry, error) {
+	var entries []cacheEntry
+
+	err := filepath.WalkDir(cacheDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if d.IsDir() || !strings.HasSuffix(path, ".zip") {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		entry := cacheEntry{
+			Path:     path,
+			Size:     info.Size(),
+			LastUsed: info.ModTime(),
+		}
+		if sumInfo, err := os.Stat(checksumPath(path)); err == nil {
+			entry.Size += sumInfo.Size()
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan cache dir: %w", err)
+	}
+
+	return entries, nil
+}
+
+// removeCacheEntry deletes an archive and its checksum file. The archive is
+// removed first so a concurrent reader never sees an archive without checksum.
+func removeCacheEntry(archivePath string) error {
+	if err := os.Remove(archivePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(checksumPath(archivePath)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// touchCacheEntry marks a cache entry as recently used for gc purposes
+func touchCacheEntry(archivePath string) {
+	now := time.Now()
+	_ = os.Chtimes(archivePath, now, now)
+}
+
+// removeStaleTempFiles removes temp files left behind by interrupted downloads.
+// Recent temp files may belong to a download in progress and are kept; each
+// is removed under the lock of the cache entry it was written for.
+func removeStaleTempFiles(ctx context.Context, cacheDir string, cfg config.Config, logger *log.Logger) {
+	cutoff := time.Now().Add(-staleTempAge)
+	_ = filepath.WalkDir(cacheDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		m := cacheTempRE.FindStringSubmatch(d.Name())
+		if m == nil {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || !info.ModTime().Before(cutoff) {
+			return nil
+		}
+
+		archivePath := filepath.Join(filepath.Dir(path), m[1]+".zip")
+		if strings.HasSuffix(m[1], ".zip.checksum") {
+			archivePath = filepath.Join(filepath.Dir(path), strings.TrimSuffix(m[1], ".checksum"))
+		}
+		lock, err := acquireFileLock(ctx, cacheLockPath(archivePath), true, lockTimeout(cfg), logger)
+		if err != nil {
+			logger.Debug("Skipping stale temp file", "path", path, "error", err)
+			return nil
+		}
+		// Check again under the lock: a download may have just started
+		if info, err := os.Stat(path); err == nil && info.ModTime().Before(cutoff) {
+			if err := os.Remove(path); err == nil {
+				logger.Debug("Removed stale temp file", "path", path)
+			}
+		}
+		if _, err := os.Stat(archivePath); os.IsNotExist(err) {
+			lock.ReleaseAndRemove()
+		} else {
+			lock.Release()
+		}
+		return nil
+	})
+}
+
+// removeEmptyDirs prunes directories left empty by eviction, keeping cacheDir
+// (or the store dir) itself
+func removeEmptyDirs(cacheDir string) {
+	var dirs []string
+	_ = filepath.WalkDir(cacheDir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && path != cacheDir {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+
+	// Deepest first so parents become empty once their children are gone
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i]) // Fails harmlessly on non-empty directories
+	}
+}
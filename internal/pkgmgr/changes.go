@@ -0,0 +1,332 @@
+package pkgmgr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/julian-richter/PhpResolver/internal/config"
+)
+
+// fileManifestName records the files of every dist-installed package, next
+// to installed.json
+const fileManifestName = "installed-files.json"
+
+// DiscardChanges is composer.json's config.discard-changes: what to do with
+// local changes to a package that is about to be updated or removed
+type DiscardChanges string
+
+const (
+	discardChangesAbort DiscardChanges = ""      // false (default): fail the install
+	discardChangesYes   DiscardChanges = "true"  // overwrite the changes
+	discardChangesStash DiscardChanges = "stash" // carry the changes over to the new version
+)
+
+func (d *DiscardChanges) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		*d = discardChangesAbort
+		if b {
+			*d = discardChangesYes
+		}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil && s == string(discardChangesStash) {
+		*d = discardChangesStash
+		return nil
+	}
+	return fmt.Errorf(`config.discard-changes must be true, false or "stash"`)
+}
+
+// localChanges are the files of an installed package that differ from what
+// was installed, relative to the package directory
+type localChanges struct {
+	Modified []string
+	Added    []string
+	Deleted  []string
+}
+
+func (c localChanges) empty() bool {
+	return len(c.Modified)+len(c.Added)+len(c.Deleted) == 0
+}
+
+// lines lists the changes in git status style, sorted by path
+func (c localChanges) lines() []string {
+	var lines []string
+	for _, f := range c.Modified {
+		lines = append(lines, "M "+f)
+	}
+	for _, f := range c.Added {
+		lines = append(lines, "A "+f)
+	}
+	for _, f := range c.Deleted {
+		lines = append(lines, "D "+f)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i][2:] < lines[j][2:] })
+	return lines
+}
+
+// fileManifest maps each dist-installed package to the sha256 of every file
+// it had when installed, keyed by slash-separated path
+type fileManifest struct {
+	Packages map[string]map[string]string `json:"packages"`
+}
+
+func fileManifestPath(vendorDir string) string {
+	return filepath.Join(vendorDir, "composer", fileManifestName)
+}
+
+// readFileManifest reads the file manifest; a missing manifest is empty
+func readFileManifest(vendorDir string) (*fileManifest, error) {
+	manifest := &fileManifest{Packages: map[string]map[string]string{}}
+	data, err := os.ReadFile(fileManifestPath(vendorDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", fileManifestName, err)
+	}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", fileManifestName, err)
+	}
+	if manifest.Packages == nil {
+		manifest.Packages = map[string]map[string]string{}
+	}
+	return manifest, nil
+}
+
+func writeFileManifest(vendorDir string, manifest *fileManifest) error {
+	return writeJSONFile(fileManifestPath(vendorDir), manifest)
+}
+
+// hashPackageFiles returns the sha256 of every regular file below dir
+func hashPackageFiles(ctx context.Context, dir string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		hashes[filepath.ToSlash(rel)] = sum
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("hash files in %s: %w", dir, err)
+	}
+	return hashes, nil
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// detectChanges compares an installed package with what was installed: git
+// status for source installs, the recorded file hashes for dist installs.
+// known is false for dist installs without recorded hashes.
+func detectChanges(ctx context.Context, pkg Package, vendorDir string, manifest *fileManifest, cfg config.Config) (changes localChanges, known bool, err error) {
+	dir := filepath.Join(vendorDir, pkg.Name)
+	if installationSource(pkg) == installFromSource {
+		changes, err := gitChanges(ctx, dir, cfg)
+		return changes, err == nil, err
+	}
+
+	recorded, ok := manifest.Packages[pkg.Name]
+	if !ok {
+		return localChanges{}, false, nil
+	}
+	current, err := hashPackageFiles(ctx, dir)
+	if err != nil {
+		return localChanges{}, false, err
+	}
+	for name, sum := range current {
+		switch old, ok := recorded[name]; {
+		case !ok:
+			changes.Added = append(changes.Added, name)
+		case old != sum:
+			changes.Modified = append(changes.Modified, name)
+		}
+	}
+	for name := range recorded {
+		if _, ok := current[name]; !ok {
+			changes.Deleted = append(changes.Deleted, name)
+		}
+	}
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Deleted)
+	return changes, true, nil
+}
+
+// gitChanges lists uncommitted changes to tracked files; like Composer it
+// ignores untracked files
+func gitChanges(ctx context.Context, dir string, cfg config.Config) (localChanges, error) {
+	out, err := gitOutput(ctx, cfg, dir, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return localChanges{}, err
+	}
+
+	var changes localChanges
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) < 4 {
+			continue
+		}
+		status, name := line[:2], line[3:]
+		switch {
+		case strings.Contains(status, "D"):
+			changes.Deleted = append(changes.Deleted, name)
+		case strings.Contains(status, "A"):
+			changes.Added = append(changes.Added, name)
+		default:
+			changes.Modified = append(changes.Modified, name)
+		}
+	}
+	return changes, nil
+}
+
+// checkLocalChanges looks for local changes in the packages a transaction
+// updates or removes and applies config.discard-changes. It returns the
+// updated packages whose changes are to be carried over to the new version.
+func checkLocalChanges(ctx context.Context, tx transaction, vendorDir string, manifest *fileManifest, mode DiscardChanges, logger *log.Logger, cfg config.Config) (map[string]localChanges, error) {
+	stashed := make(map[string]localChanges)
+	var blocked []string
+	for _, op := range tx {
+		if op.Kind != opUpdate && op.Kind != opUninstall {
+			continue
+		}
+		installed := op.Package
+		if op.Kind == opUpdate {
+			installed = op.From
+		}
+		if !dirExists(filepath.Join(vendorDir, installed.Name)) {
+			continue
+		}
+
+		changes, known, err := detectChanges(ctx, installed, vendorDir, manifest, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("check %s for local changes: %w", installed.Name, err)
+		}
+		if !known {
+			logger.Debug("No file hashes recorded, cannot detect local changes", "package", installed.Name)
+			continue
+		}
+		if changes.empty() {
+			continue
+		}
+
+		switch {
+		case mode == discardChangesYes:
+			logger.Warn("Discarding local changes", "package", installed.Name, "files", len(changes.lines()))
+		case mode == discardChangesStash && op.Kind == opUpdate && installationSource(op.From) == installationSource(op.Package):
+			logger.Info("Stashing local changes", "package", installed.Name, "files", len(changes.lines()))
+			stashed[installed.Name] = changes
+		default:
+			blocked = append(blocked, installed.Name)
+		}
+	}
+
+	if len(blocked) > 0 {
+		return nil, fmt.Errorf("local changes in %s would be lost (see the status command; set config.discard-changes to true to overwrite them, or to \"stash\" to keep them across updates)", strings.Join(blocked, ", "))
+	}
+	return stashed, nil
+}
+
+// reapplyChanges carries local changes over from the replaced version of a
+// package, now in the stage's backup, to the newly installed one. Source
+// installs apply a git diff; dist installs copy the changed files, provided
+// the new version left them as they were in the old one.
+func reapplyChanges(ctx context.Context, pkg Package, changes localChanges, stage *vendorStage, oldHashes, newHashes map[string]string, cfg config.Config) error {
+	oldDir := stage.backupPath(false, pkg.Name)
+	newDir := filepath.Join(stage.vendorDir, pkg.Name)
+
+	if installationSource(pkg) == installFromSource {
+		patch, err := gitOutput(ctx, cfg, oldDir, "diff", "--binary", "HEAD")
+		if err != nil {
+			return fmt.Errorf("stash %s: %w", pkg.Name, err)
+		}
+		patchPath, err := filepath.Abs(filepath.Join(stage.dir, strings.ReplaceAll(pkg.Name, "/", "-")+".patch"))
+		if err != nil {
+			return fmt.Errorf("stash %s: %w", pkg.Name, err)
+		}
+		if err := os.WriteFile(patchPath, []byte(patch), 0o644); err != nil {
+			return fmt.Errorf("stash %s: %w", pkg.Name, err)
+		}
+		if err := runGit(ctx, cfg, newDir, "apply", "--3way", patchPath); err != nil {
+			return fmt.Errorf("reapply local changes to %s: %w", pkg.Name, err)
+		}
+		return nil
+	}
+
+	var conflicts []string
+	for _, name := range append(append([]string{}, changes.Modified...), changes.Added...) {
+		if newHashes[name] != oldHashes[name] {
+			conflicts = append(conflicts, name)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(newDir, name)), 0o755); err != nil {
+			return fmt.Errorf("reapply local changes to %s: %w", pkg.Name, err)
+		}
+		info, err := os.Stat(filepath.Join(oldDir, name))
+		if err != nil {
+			return fmt.Errorf("reapply local changes to %s: %w", pkg.Name, err)
+		}
+		// Remove first: the new file may be a hard link into the package store
+		if err := os.Remove(filepath.Join(newDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("reapply local changes to %s: %w", pkg.Name, err)
+		}
+		if err := copyFile(filepath.Join(oldDir, name), filepath.Join(newDir, name), info.Mode().Perm()); err != nil {
+			return fmt.Errorf("reapply local changes to %s: %w", pkg.Name, err)
+		}
+	}
+	for _, name := range changes.Deleted {
+		if newHashes[name] != oldHashes[name] {
+			conflicts = append(conflicts, name)
+			continue
+		}
+		if err := os.Remove(filepath.Join(newDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("reapply local changes to %s: %w", pkg.Name, err)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("cannot reapply local changes to %s, the new version also changes %s", pkg.Name, strings.Join(conflicts, ", "))
+	}
+	return nil
+}
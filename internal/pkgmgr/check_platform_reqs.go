@@ -0,0 +1,199 @@
+package pkgmgr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/log"
+	"github.com/julian-richter/PhpResolver/internal/config"
+)
+
+// Statuses reported by check-platform-reqs
+const (
+	platformReqSuccess = "success"
+	platformReqFailed  = "failed"
+	platformReqMissing = "missing"
+)
+
+// CheckPlatformOptions holds command-line options for check-platform-reqs
+type CheckPlatformOptions struct {
+	Lock   bool   // --lock: check composer.lock instead of the installed packages
+	Format string // --format: "text" (default) or "json"
+}
+
+// platformReqResult is one requirement of one package checked against the running PHP
+type platformReqResult struct {
+	Name       string `json:"name"`
+	RequiredBy string `json:"required_by"`
+	Constraint string `json:"constraint"`
+	Version    string `json:"version,omitempty"`
+	Status     string `json:"status"`
+}
+
+// RunCheckPlatformReqs checks every platform requirement of the installed (or
+// locked) packages and the root package against the running PHP. Overrides
+// from config.platform are deliberately not applied: the point is to check the
+// real runtime. Returns an error if any requirement is not met.
+func RunCheckPlatformReqs(ctx context.Context, logger *log.Logger, cfg config.Config, opts CheckPlatformOptions) error {
+	switch opts.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("invalid format %q (must be text or json)", opts.Format)
+	}
+
+	composerPath, err := FindComposerJSON(".")
+	if err != nil {
+		return fmt.Errorf("find composer.json: %w", err)
+	}
+
+	composer, err := ParseComposerJSON(composerPath)
+	if err != nil {
+		return fmt.Errorf("parse composer.json: %w", err)
+	}
+
+	var packages []Package
+	if opts.Lock {
+		lock, err := readLockFile(lockFilePath(composerPath))
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("no %s found next to %s", lockFileName, composerPath)
+		}
+		if err != nil {
+			return err
+		}
+		packages = lock.allPackages()
+		logger.Debug("Checking locked packages", "count", len(packages))
+	} else {
+		vendorDir := filepath.Join(filepath.Dir(composerPath), "vendor")
+		installed, err := readInstalledJSON(vendorDir)
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("no installed packages found in %s (run install first, or use --lock)", vendorDir)
+		}
+		if err != nil {
+			return err
+		}
+		packages = installed.allPackages()
+		logger.Debug("Checking installed packages", "count", len(packages))
+	}
+
+	view := newPlatformView(PlatformOptions{PHPBinary: cfg.Pkgmgr.PHPBinary}, logger)
+	results, err := checkPlatformReqs(ctx, view, composer, packages)
+	if err != nil {
+		return err
+	}
+
+	if opts.Format == "json" {
+		err = writePlatformReqsJSON(os.Stdout, results)
+	} else {
+		err = writePlatformReqsTable(os.Stdout, results)
+	}
+	if err != nil {
+		return fmt.Errorf("write results: %w", err)
+	}
+
+	var failed int
+	for _, r := range results {
+		if r.Status != platformReqSuccess {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d platform requirements are not met", failed, len(results))
+	}
+	logger.Info("All platform requirements are met", "checked", len(results))
+	return nil
+}
+
+// checkPlatformReqs evaluates the root and package platform requirements,
+// sorted by requirement name and then by requiring package
+func checkPlatformReqs(ctx context.Context, view *platformView, composer ComposerJSON, packages []Package) ([]platformReqResult, error) {
+	var results []platformReqResult
+
+	consider := func(requiredBy string, require map[string]string) error {
+		for name, constraint := range require {
+			name = strings.ToLower(name)
+			if !isPlatformRequirement(name) {
+				continue
+			}
+
+			// Check for cancellation between detections
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+
+			version, ok, err := view.Version(ctx, name)
+			if err != nil {
+				return fmt.Errorf("detect platform: %w", err)
+			}
+
+			status := platformReqSuccess
+			switch {
+			case !ok:
+				status = platformReqMissing
+			case !versionSatisfies(version, constraint):
+				status = platformReqFailed
+			}
+
+			results = append(results, platformReqResult{
+				Name:       name,
+				RequiredBy: requiredBy,
+				Constraint: constraint,
+				Version:    version,
+				Status:     status,
+			})
+		}
+		return nil
+	}
+
+	if err := consider(rootRequirerName, composer.Require); err != nil {
+		return nil, err
+	}
+	if err := consider(rootRequirerName, composer.RequireDev); err != nil {
+		return nil, err
+	}
+	for _, pkg := range packages {
+		if err := consider(pkg.Name, pkg.Require); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Name != results[j].Name {
+			return results[i].Name < results[j].Name
+		}
+		return results[i].RequiredBy < results[j].RequiredBy
+	})
+	return results, nil
+}
+
+func writePlatformReqsTable(w io.Writer, results []platformReqResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REQUIREMENT\tVERSION\tCONSTRAINT\tREQUIRED BY\tSTATUS")
+	for _, r := range results {
+		version := r.Version
+		if version == "" {
+			version = "n/a"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Name, version, r.Constraint, r.RequiredBy, r.Status)
+	}
+	return tw.Flush()
+}
+
+func writePlatformReqsJSON(w io.Writer, results []platformReqResult) error {
+	if results == nil {
+		results = []platformReqResult{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	return enc.Encode(results)
+}
@@ -0,0 +1,149 @@
+package pkgmgr
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultChecksumAlgo is used to record a checksum for archives whose
+// repository metadata does not provide one
+const defaultChecksumAlgo = "sha256"
+
+// Checksum is an algorithm-qualified digest such as "sha256:9f86d0...".
+type Checksum struct {
+	Algo string
+	Hex  string
+}
+
+func (c Checksum) String() string {
+	return c.Algo + ":" + c.Hex
+}
+
+// Matches reports whether the hex digest equals c, ignoring case
+func (c Checksum) Matches(hexDigest string) bool {
+	return strings.EqualFold(c.Hex, hexDigest)
+}
+
+// ParseChecksum parses either an "algo:hex" string (as used by private
+// repositories) or a bare hex digest, in which case the algorithm is
+// inferred from the digest length (Packagist's shasum is a bare SHA-1).
+func ParseChecksum(s string) (Checksum, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Checksum{}, fmt.Errorf("empty checksum")
+	}
+
+	algo, digest, found := strings.Cut(s, ":")
+	if !found {
+		digest = s
+		switch len(digest) {
+		case sha1.Size * 2:
+			algo = "sha1"
+		case sha256.Size * 2:
+			algo = "sha256"
+		case sha512.Size384 * 2:
+			algo = "sha384"
+		case sha512.Size * 2:
+			algo = "sha512"
+		default:
+			return Checksum{}, fmt.Errorf("cannot infer algorithm for checksum %q", s)
+		}
+	}
+
+	algo = strings.ToLower(algo)
+	h, err := newHasher(algo)
+	if err != nil {
+		return Checksum{}, err
+	}
+	if len(digest) != h.Size()*2 {
+		return Checksum{}, fmt.Errorf("invalid %s checksum length %d", algo, len(digest))
+	}
+	for _, r := range digest {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return Checksum{}, fmt.Errorf("invalid hex in checksum %q", s)
+		}
+	}
+
+	return Checksum{Algo: algo, Hex: strings.ToLower(digest)}, nil
+}
+
+func newHasher(algo string) (hash.Hash, error) {
+	switch algo {
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha384":
+		return sha512.New384(), nil
+	case "sha512":
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", algo)
+	}
+}
+
+// expectedChecksum returns the checksum advertised by the dist metadata, if any.
+// Checksum takes precedence over the legacy shasum field.
+func expectedChecksum(dist Dist) (Checksum, bool, error) {
+	raw := dist.Checksum
+	if raw == "" {
+		raw = dist.Shasum
+	}
+	if raw == "" {
+		return Checksum{}, false, nil
+	}
+	sum, err := ParseChecksum(raw)
+	if err != nil {
+		return Checksum{}, false, err
+	}
+	return sum, true, nil
+}
+
+// checksumPath returns the path of the checksum file recorded next to a cached archive
+func checksumPath(archivePath string) string {
+	return archivePath + ".checksum"
+}
+
+// readRecordedChecksum reads the checksum recorded next to a cached archive
+func readRecordedChecksum(archivePath string) (Checksum, error) {
+	data, err := os.ReadFile(checksumPath(archivePath))
+	if err != nil {
+		return Checksum{}, err
+	}
+	return ParseChecksum(string(data))
+}
+
+// writeRecordedChecksum atomically writes the checksum file for a cached archive
+func writeRecordedChecksum(archivePath string, sum Checksum) error {
+	path := checksumPath(archivePath)
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("create temp checksum file: %w", err)
+	}
+	tempPath := tempFile.Name()
+
+	if _, err := tempFile.WriteString(sum.String() + "\n"); err != nil {
+		tempFile.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("write checksum file: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("close checksum file: %w", err)
+	}
+	if err := os.Chmod(tempPath, 0o644); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("set checksum file permissions: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("rename checksum file: %w", err)
+	}
+	return nil
+}
@@ -2,21 +2,20 @@ package pkgmgr
 
 import (
 	"context"
-	"crypto/sha1"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sync"
-	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/julian-richter/PhpResolver/internal/config"
 )
 
-func DownloadPackages(ctx context.Context, packages []Package, cacheDir string, logger *log.Logger, cfg config.Config) error {
+func DownloadPackages(ctx context.Context, client *HTTPClient, packages []Package, cacheDir string, logger *log.Logger, cfg config.Config) error {
 	// Create a cancellable context to stop all downloads on first error
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -38,7 +37,7 @@ func DownloadPackages(ctx context.Context, packages []Package, cacheDir string,
 				return // Context cancelled, exit without acquiring semaphore
 			}
 
-			if err := downloadPackage(ctx, pkg, cacheDir, logger); err != nil {
+			if err := downloadPackage(ctx, client, pkg, cacheDir, logger, cfg); err != nil {
 				select {
 				case errCh <- fmt.Errorf("package %s: %w", pkg.Name, err):
 				case <-ctx.Done():
@@ -64,20 +63,56 @@ func DownloadPackages(ctx context.Context, packages []Package, cacheDir string,
 	return nil
 }
 
-func downloadPackage(ctx context.Context, pkg Package, cacheDir string, logger *log.Logger) error {
-	cachePath := filepath.Join(cacheDir, pkg.Name, pkg.Version, fmt.Sprintf("%s.zip", pkg.Name))
+// cacheArchivePath returns the location of a package's dist archive in the download cache:
+// ~/.phpResolver/cache/vendor/package/version/vendor/package.zip
+func cacheArchivePath(cacheDir string, pkg Package) string {
+	return filepath.Join(cacheDir, pkg.Name, pkg.Version, fmt.Sprintf("%s.zip", pkg.Name))
+}
+
+func downloadPackage(ctx context.Context, client *HTTPClient, pkg Package, cacheDir string, logger *log.Logger, cfg config.Config) error {
+	cachePath := cacheArchivePath(cacheDir, pkg)
 	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
 		return fmt.Errorf("create cache dir: %w", err)
 	}
 
-	// Skip if already exists (idempotent)
-	if _, err := os.Stat(cachePath); err == nil {
-		logger.Debug("Package already cached", "path", cachePath)
-		return nil
+	// Hold the entry lock for the whole check-download-commit sequence so
+	// parallel runs sharing the cache download each archive only once
+	lock, err := acquireFileLock(ctx, cacheLockPath(cachePath), true, lockTimeout(cfg), logger)
+	if err != nil {
+		return fmt.Errorf("lock cache entry: %w", err)
 	}
+	defer lock.Release()
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	// Determine which checksum to verify against. Without one in the metadata we
+	// still hash the archive so the cache entry can be re-verified later.
+	expected, hasExpected, err := expectedChecksum(pkg.Dist)
+	if err != nil {
+		return fmt.Errorf("invalid dist checksum: %w", err)
+	}
+
+	// Skip if already cached (idempotent). Entries without a recorded checksum
+	// predate checksum recording, and entries that don't match the metadata's
+	// checksum are corrupt or were republished; both are re-downloaded.
+	if _, err := os.Stat(cachePath); err == nil {
+		recorded, err := readRecordedChecksum(cachePath)
+		switch {
+		case err != nil:
+			logger.Debug("Cached package has no valid recorded checksum, re-downloading", "path", cachePath)
+		case hasExpected && !cachedArchiveMatches(cachePath, recorded, expected):
+			logger.Warn("Cached package does not match the expected checksum, re-downloading", "package", pkg.Name, "version", pkg.Version, "expected", expected.String())
+		default:
+			logger.Debug("Package already cached", "path", cachePath)
+			touchCacheEntry(cachePath)
+			return nil
+		}
+	}
+	algo := defaultChecksumAlgo
+	if hasExpected {
+		algo = expected.Algo
+	}
+	hasher, err := newHasher(algo)
+	if err != nil {
+		return err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", pkg.Dist.URL, nil)
@@ -91,6 +126,9 @@ func downloadPackage(ctx context.Context, pkg Package, cacheDir string, logger *
 	}
 	defer resp.Body.Close()
 
+	if isAuthStatus(resp.StatusCode) {
+		return authError(resp.Request.URL, resp.Status)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("HTTP %d from %s", resp.StatusCode, pkg.Dist.URL)
 	}
@@ -117,10 +155,16 @@ func downloadPackage(ctx context.Context, pkg Package, cacheDir string, logger *
 		}
 	}()
 
-	if _, err := io.Copy(tempFile, resp.Body); err != nil {
+	// Hash while streaming so the archive never has to be re-read
+	if _, err := io.Copy(io.MultiWriter(tempFile, hasher), resp.Body); err != nil {
 		return fmt.Errorf("write temp file: %w", err)
 	}
 
+	actual := Checksum{Algo: algo, Hex: hex.EncodeToString(hasher.Sum(nil))}
+	if hasExpected && !expected.Matches(actual.Hex) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expected, actual)
+	}
+
 	// Sync to ensure data is written to disk
 	if err := tempFile.Sync(); err != nil {
 		return fmt.Errorf("sync temp file: %w", err)
@@ -130,42 +174,47 @@ func downloadPackage(ctx context.Context, pkg Package, cacheDir string, logger *
 	if err := tempFile.Close(); err != nil {
 		return fmt.Errorf("close temp file: %w", err)
 	}
-	tempFile = nil // Prevent cleanup
 
-	// Atomically rename temp file to final location
+	// Drop any stale checksum, then atomically rename the verified temp file
+	// to its final location
+	if err := os.Remove(checksumPath(cachePath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove stale checksum: %w", err)
+	}
 	if err := os.Rename(tempPath, cachePath); err != nil {
-		os.Remove(tempPath) // Clean up temp file on rename failure
 		return fmt.Errorf("rename temp file to cache: %w", err)
 	}
+	tempFile = nil // Prevent cleanup
 
-	// Verify checksum if provided
-	if pkg.Dist.Checksum != "" || pkg.Dist.Shasum != "" {
-		expectedHash := pkg.Dist.Checksum
-		if expectedHash == "" {
-			expectedHash = pkg.Dist.Shasum
-		}
-
-		// Reopen file for verification
-		file, err := os.Open(cachePath)
-		if err != nil {
-			return fmt.Errorf("reopen cache file for verification: %w", err)
-		}
-		defer file.Close()
+	// Record the checksum only once the archive is in place, so it never
+	// describes a missing or different archive. An archive without one is
+	// re-downloaded rather than trusted.
+	if err := writeRecordedChecksum(cachePath, actual); err != nil {
+		return fmt.Errorf("record checksum: %w", err)
+	}
 
-		// Compute SHA1 hash
-		hasher := sha1.New()
-		if _, err := io.Copy(hasher, file); err != nil {
-			return fmt.Errorf("compute checksum: %w", err)
-		}
+	logger.Info("Downloaded", "package", pkg.Name, "version", pkg.Version, "path", cachePath, "checksum", actual.String())
+	return nil
+}
 
-		actualHash := hex.EncodeToString(hasher.Sum(nil))
-		if actualHash != expectedHash {
-			// Remove corrupted file
-			os.Remove(cachePath)
-			return fmt.Errorf("checksum mismatch: expected %s, got %s", expectedHash, actualHash)
-		}
+// cachedArchiveMatches reports whether a cached archive has the checksum the
+// repository metadata expects. The recorded checksum is compared when it uses
+// the same algorithm, otherwise the archive is hashed with the expected one.
+func cachedArchiveMatches(cachePath string, recorded, expected Checksum) bool {
+	if recorded.Algo == expected.Algo {
+		return expected.Matches(recorded.Hex)
 	}
 
-	logger.Info("Downloaded", "package", pkg.Name, "version", pkg.Version, "path", cachePath)
-	return nil
+	hasher, err := newHasher(expected.Algo)
+	if err != nil {
+		return false
+	}
+	file, err := os.Open(cachePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return false
+	}
+	return expected.Matches(hex.EncodeToString(hasher.Sum(nil)))
 }
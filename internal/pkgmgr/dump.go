@@ -3,6 +3,7 @@ package pkgmgr
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/charmbracelet/log"
@@ -11,8 +12,8 @@ import (
 
 // RunDumpAutoload generates the composer autoloader. Unlike RunInstall/RunUpdate which
 // perform network operations requiring concurrency limits and cancellation, this function
-// operates synchronously on local files. The cfg parameter is accepted for API consistency
-// but currently unused since autoloader generation has no configurable behavior.
+// operates synchronously on local files. The cfg parameter only supplies the project
+// lock timeout since autoloader generation has no other configurable behavior.
 // Context is respected for cancellation consistency with other operations.
 func RunDumpAutoload(ctx context.Context, logger *log.Logger, cfg config.Config) error {
 	composerPath, err := FindComposerJSON(".")
@@ -26,8 +27,18 @@ func RunDumpAutoload(ctx context.Context, logger *log.Logger, cfg config.Config)
 	}
 
 	vendorDir := filepath.Join(filepath.Dir(composerPath), "vendor")
+	if err := os.MkdirAll(vendorDir, 0o755); err != nil {
+		return fmt.Errorf("create vendor dir: %w", err)
+	}
 	logger.Info("Generating autoloader", "vendor_dir", vendorDir)
 
+	// Don't write the autoloader while an install in another process is changing vendor/
+	projectLock, err := acquireProjectLock(ctx, vendorDir, cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer projectLock.Release()
+
 	// Check for cancellation before the potentially slow autoloader generation
 	select {
 	case <-ctx.Done():
@@ -35,7 +46,15 @@ func RunDumpAutoload(ctx context.Context, logger *log.Logger, cfg config.Config)
 	default:
 	}
 
-	if err := GenerateAutoloader(ctx, composer.Autoload, vendorDir, logger); err != nil {
+	// Keep including the platform check written by the last install/update
+	includeCheck := false
+	if composer.Config.PlatformCheck.enabled() {
+		if _, err := os.Stat(filepath.Join(vendorDir, "composer", platformCheckFileName)); err == nil {
+			includeCheck = true
+		}
+	}
+
+	if err := GenerateAutoloader(ctx, composer.Autoload, vendorDir, includeCheck, logger); err != nil {
 		return fmt.Errorf("generate autoloader: %w", err)
 	}
 
@@ -4,32 +4,56 @@ import (
 	"archive/zip"
 	"context"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/charmbracelet/log"
+	"github.com/julian-richter/PhpResolver/internal/config"
 )
 
 // ExtractPackages extracts downloaded zip files to vendor directory
-// following Composer's vendor/vendor-name/package-name structure
-func ExtractPackages(ctx context.Context, packages []Package, cacheDir, vendorDir string, logger *log.Logger) error {
+// following Composer's vendor/vendor-name/package-name structure. Up to
+// max_concurrent_extractions packages are extracted at once; a failing
+// package does not stop the others.
+func ExtractPackages(ctx context.Context, packages []Package, cacheDir, vendorDir string, logger *log.Logger, cfg config.Config) error {
 	var errors []string
 	var failedPackages []string
 
-	for _, pkg := range packages {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
+	// Each worker records its result by index so errors are reported in
+	// package order regardless of which extraction finished first
+	results := make([]error, len(packages))
+	sem := make(chan struct{}, max(cfg.Pkgmgr.MaxConcurrentExtractions, 1))
+	var wg sync.WaitGroup
+
+	for i, pkg := range packages {
+		wg.Add(1)
+		go func(i int, pkg Package) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}: // Acquired semaphore
+				defer func() { <-sem }() // Release semaphore
+			case <-ctx.Done():
+				results[i] = ctx.Err()
+				return
+			}
+
+			results[i] = extractPackage(ctx, pkg, cacheDir, vendorDir, logger, cfg)
+		}(i, pkg)
+	}
+	wg.Wait()
 
-		if err := extractPackage(ctx, pkg, cacheDir, vendorDir, logger); err != nil {
-			logger.Error("Failed to extract package", "package", pkg.Name, "error", err)
-			errors = append(errors, fmt.Sprintf("%s: %v", pkg.Name, err))
-			failedPackages = append(failedPackages, pkg.Name)
-			continue // Continue with remaining packages
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for i, err := range results {
+		if err != nil {
+			logger.Error("Failed to extract package", "package", packages[i].Name, "error", err)
+			errors = append(errors, fmt.Sprintf("%s: %v", packages[i].Name, err))
+			failedPackages = append(failedPackages, packages[i].Name)
 		}
 	}
 
@@ -52,9 +76,8 @@ func ExtractPackages(ctx context.Context, packages []Package, cacheDir, vendorDi
 	return nil
 }
 
-func extractPackage(ctx context.Context, pkg Package, cacheDir, vendorDir string, logger *log.Logger) error {
-	// Build cache path: ~/.phpResolver/cache/vendor/package/version/vendor-package.zip
-	cachePath := filepath.Join(cacheDir, pkg.Name, pkg.Version, fmt.Sprintf("%s.zip", pkg.Name))
+func extractPackage(ctx context.Context, pkg Package, cacheDir, vendorDir string, logger *log.Logger, cfg config.Config) error {
+	cachePath := cacheArchivePath(cacheDir, pkg)
 
 	// Build vendor path: vendor/vendor-name/package-name/
 	vendorPath := filepath.Join(vendorDir, pkg.Name)
@@ -77,28 +100,13 @@ func extractPackage(ctx context.Context, pkg Package, cacheDir, vendorDir string
 		}
 	}()
 
-	// Open zip file
-	zipReader, err := zip.OpenReader(cachePath)
-	if err != nil {
-		return fmt.Errorf("open zip file %s: %w", cachePath, err)
-	}
-	defer zipReader.Close()
-
-	// Extract files
-	// Composer zip files typically have a root directory with the package name
-	// We need to strip that root directory when extracting
-	rootDir := computeCommonPrefix(zipReader.File)
-
-	for _, file := range zipReader.File {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-
-		if err := extractZipFile(file, tempDir, rootDir, logger); err != nil {
-			return fmt.Errorf("extract file %s: %w", file.Name, err)
+	// Fill the temp directory from the archive, or from the shared store
+	if cfg.Pkgmgr.InstallMode == config.InstallModeLink {
+		if err := linkFromStore(ctx, pkg, cachePath, tempDir, logger, cfg); err != nil {
+			return err
 		}
+	} else if err := unpackArchive(ctx, cachePath, tempDir, logger, cfg); err != nil {
+		return err
 	}
 
 	// Perform atomic directory swap to avoid data loss
@@ -141,7 +149,51 @@ func extractPackage(ctx context.Context, pkg Package, cacheDir, vendorDir string
 	return nil
 }
 
-func extractZipFile(file *zip.File, destDir, stripPrefix string, logger *log.Logger) error {
+// unpackArchive extracts a cached dist archive into destDir, stripping the
+// archive's common root directory
+func unpackArchive(ctx context.Context, cachePath, destDir string, logger *log.Logger, cfg config.Config) error {
+	// Hold a shared lock on the cache entry while reading so cache gc or
+	// verify in another process cannot remove the archive underneath us
+	cacheLock, err := acquireFileLock(ctx, cacheLockPath(cachePath), false, lockTimeout(cfg), logger)
+	if err != nil {
+		return fmt.Errorf("lock cache entry: %w", err)
+	}
+	defer cacheLock.Release()
+
+	// Open zip file
+	zipReader, err := zip.OpenReader(cachePath)
+	if err != nil {
+		return fmt.Errorf("open zip file %s: %w", cachePath, err)
+	}
+	defer zipReader.Close()
+
+	// Extract files
+	// Composer zip files typically have a root directory with the package name
+	// We need to strip that root directory when extracting
+	rootDir := computeCommonPrefix(zipReader.File)
+
+	// Reject zip bombs and malformed entries before writing anything
+	limits := extractLimitsFromConfig(cfg)
+	if err := checkZipEntries(zipReader.File, limits); err != nil {
+		return fmt.Errorf("check %s: %w", cachePath, err)
+	}
+	budget := &extractBudget{limits: limits}
+
+	for _, file := range zipReader.File {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if err := extractZipFile(file, destDir, rootDir, budget, logger); err != nil {
+			return fmt.Errorf("extract file %s: %w", file.Name, err)
+		}
+	}
+	return nil
+}
+
+func extractZipFile(file *zip.File, destDir, stripPrefix string, budget *extractBudget, logger *log.Logger) error {
 	// Get the file path relative to strip prefix
 	relativePath := file.Name
 	if stripPrefix != "" && strings.HasPrefix(relativePath, stripPrefix) {
@@ -184,7 +236,7 @@ func extractZipFile(file *zip.File, destDir, stripPrefix string, logger *log.Log
 	}
 	defer destFile.Close()
 
-	if _, err := io.Copy(destFile, srcFile); err != nil {
+	if err := budget.copyEntry(destFile, srcFile, file.Name); err != nil {
 		return fmt.Errorf("copy file contents: %w", err)
 	}
 
@@ -0,0 +1,240 @@
+package pkgmgr
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/julian-richter/PhpResolver/internal/config"
+)
+
+// HTTPClient is the client shared by repository lookups and downloads. It
+// carries the project's secure-http/disable-tls policy so callers can tell
+// which URLs are usable before requesting them.
+type HTTPClient struct {
+	*http.Client
+	policy urlPolicy
+}
+
+// HTTPOptions configures the shared transport
+type HTTPOptions struct {
+	Timeout        time.Duration
+	ConnectTimeout time.Duration
+	CAFile         string
+	CAPath         string
+	DisableTLS     bool
+	SecureHTTP     bool
+}
+
+// urlPolicy mirrors Composer's secure-http and disable-tls settings
+type urlPolicy struct {
+	disableTLS bool
+	secureHTTP bool
+}
+
+// HTTPOptionsFromConfig merges phpResolver's config with the project's
+// composer.json config; project settings win. Relative CA paths in
+// composer.json are resolved against projectDir.
+func HTTPOptionsFromConfig(cfg config.Config, composerCfg Config, projectDir string) HTTPOptions {
+	opts := HTTPOptions{
+		Timeout:        time.Duration(cfg.Pkgmgr.HTTPTimeoutSeconds) * time.Second,
+		ConnectTimeout: time.Duration(cfg.Pkgmgr.HTTPConnectTimeoutSeconds) * time.Second,
+		CAFile:         cfg.Pkgmgr.CAFile,
+		CAPath:         cfg.Pkgmgr.CAPath,
+		DisableTLS:     composerCfg.DisableTLS,
+		SecureHTTP:     true,
+	}
+
+	if composerCfg.CAFile != "" {
+		opts.CAFile = resolveProjectPath(projectDir, composerCfg.CAFile)
+	}
+	if composerCfg.CAPath != "" {
+		opts.CAPath = resolveProjectPath(projectDir, composerCfg.CAPath)
+	}
+	if composerCfg.SecureHTTP != nil {
+		opts.SecureHTTP = *composerCfg.SecureHTTP
+	}
+
+	return opts
+}
+
+func resolveProjectPath(projectDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(projectDir, path)
+}
+
+// NewHTTPClient builds the shared client. Proxies are read from HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY; extra CA certificates are added to the system pool.
+// Credentials from auth are applied per host by the transport.
+func NewHTTPClient(opts HTTPOptions, auth *AuthConfig) (*HTTPClient, error) {
+	rootCAs, err := loadRootCAs(opts.CAFile, opts.CAPath)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   opts.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.Proxy = http.ProxyFromEnvironment
+	base.DialContext = dialer.DialContext
+	base.TLSHandshakeTimeout = opts.ConnectTimeout
+	base.TLSClientConfig = &tls.Config{
+		RootCAs:    rootCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	policy := urlPolicy{disableTLS: opts.DisableTLS, secureHTTP: opts.SecureHTTP}
+
+	return &HTTPClient{
+		Client: &http.Client{
+			Timeout: opts.Timeout,
+			Transport: &policyTransport{
+				policy: policy,
+				base: &authTransport{
+					base: base,
+					auth: auth,
+				},
+			},
+		},
+		policy: policy,
+	}, nil
+}
+
+// newProjectHTTPClient builds the shared client for the project rooted at
+// projectDir, loading its credentials and TLS settings
+func newProjectHTTPClient(projectDir string, composer ComposerJSON, cfg config.Config, logger *log.Logger) (*HTTPClient, error) {
+	auth, err := LoadAuthConfig(projectDir)
+	if err != nil {
+		return nil, fmt.Errorf("load auth config: %w", err)
+	}
+
+	opts := HTTPOptionsFromConfig(cfg, composer.Config, projectDir)
+	if opts.DisableTLS {
+		logger.Warn("TLS is disabled (config.disable-tls); HTTPS URLs are fetched over plain HTTP")
+	}
+	if !opts.SecureHTTP {
+		logger.Warn("Insecure HTTP URLs are allowed (config.secure-http is false)")
+	}
+
+	client, err := NewHTTPClient(opts, auth)
+	if err != nil {
+		return nil, fmt.Errorf("create http client: %w", err)
+	}
+	return client, nil
+}
+
+// AllowsURL reports whether rawURL may be fetched under the client's policy
+func (c *HTTPClient) AllowsURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	_, err = c.policy.apply(u)
+	return err == nil
+}
+
+// apply rejects u if secure-http forbids its scheme and then, like
+// Composer, rewrites HTTPS to HTTP according to disable-tls
+func (p urlPolicy) apply(u *url.URL) (*url.URL, error) {
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if p.secureHTTP {
+			return nil, fmt.Errorf("your configuration does not allow connections to %s (set config.secure-http to false to allow it)", u.Redacted())
+		}
+	default:
+		return nil, fmt.Errorf("unsupported URL scheme %q in %s", u.Scheme, u.Redacted())
+	}
+
+	if u.Scheme == "https" && p.disableTLS {
+		rewritten := *u
+		rewritten.Scheme = "http"
+		u = &rewritten
+	}
+	return u, nil
+}
+
+// policyTransport enforces the URL policy on every request, including each
+// hop of a redirect chain, so an HTTPS URL can't be redirected to plain HTTP
+type policyTransport struct {
+	base   http.RoundTripper
+	policy urlPolicy
+}
+
+func (t *policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, err := t.policy.apply(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u != req.URL {
+		req = req.Clone(req.Context())
+		req.URL = u
+		req.Host = ""
+	}
+	return t.base.RoundTrip(req)
+}
+
+// loadRootCAs returns the system pool extended with certificates from caFile
+// and every PEM file in caPath, or nil (system defaults) when neither is set
+func loadRootCAs(caFile, caPath string) (*x509.CertPool, error) {
+	if caFile == "" && caPath == "" {
+		return nil, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read cafile: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("cafile %s contains no PEM certificates", caFile)
+		}
+	}
+
+	if caPath != "" {
+		entries, err := os.ReadDir(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("read capath: %w", err)
+		}
+		var added int
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(caPath, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("read capath certificate: %w", err)
+			}
+			if pool.AppendCertsFromPEM(data) {
+				added++
+			}
+		}
+		if added == 0 {
+			return nil, fmt.Errorf("capath %s contains no PEM certificates", caPath)
+		}
+	}
+
+	return pool, nil
+}
+
+// isAuthStatus reports whether an HTTP status means credentials are missing or wrong
+func isAuthStatus(code int) bool {
+	return code == http.StatusUnauthorized || code == http.StatusForbidden
+}
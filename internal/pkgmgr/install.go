@@ -3,6 +3,7 @@ package pkgmgr
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,7 +12,34 @@ import (
 	"github.com/julian-richter/PhpResolver/internal/config"
 )
 
-func RunInstall(ctx context.Context, logger *log.Logger, cfg config.Config) error {
+// InstallOptions holds command-line options shared by install and update
+type InstallOptions struct {
+	IgnorePlatformReqs bool     // --ignore-platform-reqs
+	IgnorePlatformReq  []string // --ignore-platform-req=ext-foo, repeatable
+	PreferSource       bool     // --prefer-source, overrides config.preferred-install
+	PreferDist         bool     // --prefer-dist, overrides config.preferred-install
+}
+
+// resolveOptions builds the resolver configuration for a project
+func resolveOptions(composer ComposerJSON, cfg config.Config, opts InstallOptions) ResolveOptions {
+	return ResolveOptions{
+		Repositories: composer.Repositories,
+		Platform: PlatformOptions{
+			PHPBinary:  cfg.Pkgmgr.PHPBinary,
+			IgnoreAll:  opts.IgnorePlatformReqs,
+			IgnoreReqs: opts.IgnorePlatformReq,
+			Overrides:  composer.Config.Platform,
+		},
+		Concurrency:      cfg.Pkgmgr.MaxConcurrentDownloads,
+		MinimumStability: composer.MinimumStability,
+		PreferStable:     composer.PreferStable,
+		Replace:          composer.Replace,
+		Provide:          composer.Provide,
+		Conflict:         composer.Conflict,
+	}
+}
+
+func RunInstall(ctx context.Context, logger *log.Logger, cfg config.Config, opts InstallOptions) error {
 	composerPath, err := FindComposerJSON(".")
 	if err != nil {
 		return fmt.Errorf("find composer.json: %w", err)
@@ -28,36 +56,172 @@ func RunInstall(ctx context.Context, logger *log.Logger, cfg config.Config) erro
 		return fmt.Errorf("create vendor dir: %w", err)
 	}
 
-	// Create cache dir
-	home, err := os.UserHomeDir()
+	// Serialize runs that modify the same vendor/ tree
+	projectLock, err := acquireProjectLock(ctx, vendorDir, cfg, logger)
 	if err != nil {
-		return fmt.Errorf("get user home dir: %w", err)
+		return err
 	}
-	cacheDir := filepath.Join(home, ".phpResolver", "cache")
-	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
-		return fmt.Errorf("create cache dir: %w", err)
+	defer projectLock.Release()
+
+	// Shared HTTP client with per-host credentials, proxy and TLS settings
+	client, err := newProjectHTTPClient(filepath.Dir(composerPath), composer, cfg, logger)
+	if err != nil {
+		return err
 	}
 
-	// Resolve packages from custom repositories and Packagist
-	packages, err := ResolvePackagesWithRepos(ctx, composer.Require, composer.Repositories, logger)
+	// Resolve packages and their dependencies from custom repositories and Packagist
+	ropts := resolveOptions(composer, cfg, opts)
+	packages, err := ResolvePackages(ctx, client, rootRequirements(composer), ropts, logger)
 	if err != nil {
 		return fmt.Errorf("resolve packages: %w", err)
 	}
 
+	devNames := devPackageNames(composer, packages)
+	if err := installPackages(ctx, client, composer, packages, devNames, opts, vendorDir, logger, cfg); err != nil {
+		return err
+	}
+
+	logger.Info("Installation complete", "vendor_dir", vendorDir)
+	return nil
+}
+
+// rootRequirements returns the root package's require and require-dev
+// entries, which are resolved together
+func rootRequirements(composer ComposerJSON) map[string]string {
+	require := make(map[string]string, len(composer.Require)+len(composer.RequireDev))
+	for name, constraint := range composer.RequireDev {
+		require[name] = constraint
+	}
+	for name, constraint := range composer.Require {
+		require[name] = constraint
+	}
+	return require
+}
+
+// installPackages brings vendorDir in line with packages: only packages that
+// are new or changed compared to installed.json are downloaded and extracted
+// or cloned from source, packages no longer needed are removed.
+// installed.json, the platform check and the autoloader are always
+// rewritten. Changes are staged and committed together; on failure or
+// interrupt every package is rolled back.
+func installPackages(ctx context.Context, client *HTTPClient, composer ComposerJSON, packages []Package, devNames []string, opts InstallOptions, vendorDir string, logger *log.Logger, cfg config.Config) (err error) {
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return err
+	}
+
+	stage, err := newVendorStage(vendorDir, logger)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		logger.Warn("Rolling back vendor changes", "error", err)
+		if rollbackErr := stage.rollback(); rollbackErr != nil {
+			err = errors.Join(err, rollbackErr)
+		}
+	}()
+
+	// Without installed.json there is no record of what an earlier run
+	// installed, so everything is (re)installed and nothing removed
+	var installed []Package
+	record, err := readInstalledJSON(vendorDir)
+	switch {
+	case err == nil:
+		installed = record.allPackages()
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	packages = withInstallationSources(packages, composer.Config.PreferredInstall, opts)
+	tx := planTransaction(installed, packages, vendorDir)
+	tx.log(logger)
+	changed := tx.packages(opInstall, opUpdate)
+	fromDist, fromSource := splitByInstallationSource(changed)
+
+	// Refuse to overwrite local edits unless config.discard-changes says so
+	manifest, err := readFileManifest(vendorDir)
+	if err != nil {
+		return err
+	}
+	stashed, err := checkLocalChanges(ctx, tx, vendorDir, manifest, composer.Config.DiscardChanges, logger, cfg)
+	if err != nil {
+		return err
+	}
+
 	// Download with configurable concurrency
-	if err := DownloadPackages(ctx, packages, cacheDir, logger, cfg); err != nil {
+	if err := DownloadPackages(ctx, client, fromDist, cacheDir, logger, cfg); err != nil {
 		return fmt.Errorf("download packages: %w", err)
 	}
 
-	// Extract packages from cache to vendor/
-	if err := ExtractPackages(ctx, packages, cacheDir, vendorDir, logger); err != nil {
+	// Extract packages from cache to the staging directory
+	if err := ExtractPackages(ctx, fromDist, cacheDir, stage.packagesDir(), logger, cfg); err != nil {
 		return fmt.Errorf("extract packages: %w", err)
 	}
 
-	if err := GenerateAutoloader(ctx, composer.Autoload, vendorDir, logger); err != nil {
+	if err := CloneSources(ctx, fromSource, stage.packagesDir(), logger, cfg); err != nil {
+		return fmt.Errorf("clone sources: %w", err)
+	}
+
+	// Record the pristine files of new dist installs to detect later edits
+	hashes := make(map[string]map[string]string, len(fromDist))
+	for _, pkg := range fromDist {
+		if hashes[pkg.Name], err = hashPackageFiles(ctx, filepath.Join(stage.packagesDir(), pkg.Name)); err != nil {
+			return err
+		}
+	}
+
+	if err := stage.backupFiles(); err != nil {
+		return err
+	}
+
+	scripts := newScriptRunner(composer, vendorDir, cfg, logger)
+	if err := uninstallPackages(ctx, tx.packages(opUninstall), stage, scripts, logger); err != nil {
+		return fmt.Errorf("remove unused packages: %w", err)
+	}
+
+	for _, pkg := range changed {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if err := stage.install(pkg.Name); err != nil {
+			return err
+		}
+		if changes, ok := stashed[pkg.Name]; ok {
+			if err := reapplyChanges(ctx, pkg, changes, stage, manifest.Packages[pkg.Name], hashes[pkg.Name], cfg); err != nil {
+				return err
+			}
+			logger.Info("Reapplied local changes", "package", pkg.Name)
+		}
+	}
+
+	if err := writeInstalledJSON(vendorDir, packages, devNames); err != nil {
+		return fmt.Errorf("write installed.json: %w", err)
+	}
+
+	for _, pkg := range tx.packages(opInstall, opUpdate, opUninstall) {
+		delete(manifest.Packages, pkg.Name)
+	}
+	for name, files := range hashes {
+		manifest.Packages[name] = files
+	}
+	if err := writeFileManifest(vendorDir, manifest); err != nil {
+		return fmt.Errorf("write %s: %w", fileManifestName, err)
+	}
+
+	platform := resolveOptions(composer, cfg, opts).Platform
+	includeCheck, err := GeneratePlatformCheck(ctx, packages, composer.Require, composer.Config.PlatformCheck, platform, vendorDir, logger)
+	if err != nil {
+		return fmt.Errorf("generate platform check: %w", err)
+	}
+
+	if err := GenerateAutoloader(ctx, composer.Autoload, vendorDir, includeCheck, logger); err != nil {
 		return fmt.Errorf("generate autoloader: %w", err)
 	}
 
-	logger.Info("Installation complete", "vendor_dir", vendorDir)
-	return nil
+	return stage.commit()
 }
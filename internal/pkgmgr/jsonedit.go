@@ -0,0 +1,329 @@
+package pkgmgr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// composer.json is edited in place rather than re-encoded so that key order,
+// indentation and formatting elsewhere in the file survive, like Composer's
+// JsonManipulator does.
+
+var errMalformedJSON = errors.New("malformed JSON")
+
+// jsonMember is an object member located in the source text. Offsets are
+// byte positions: key from KeyStart, value from ValueStart up to ValueEnd.
+type jsonMember struct {
+	Key        string
+	KeyStart   int
+	ValueStart int
+	ValueEnd   int
+}
+
+// jsonObject is an object located in the source text; Start is the offset
+// of '{' and End the offset just past '}'
+type jsonObject struct {
+	Start   int
+	End     int
+	Members []jsonMember
+}
+
+func (o jsonObject) member(key string) (jsonMember, bool) {
+	for _, m := range o.Members {
+		if strings.EqualFold(m.Key, key) {
+			return m, true
+		}
+	}
+	return jsonMember{}, false
+}
+
+func skipWhitespace(src []byte, i int) int {
+	for i < len(src) && (src[i] == ' ' || src[i] == '\t' || src[i] == '\n' || src[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// scanJSONValue returns the offset just past the value starting at i
+func scanJSONValue(src []byte, i int) (int, error) {
+	i = skipWhitespace(src, i)
+	if i >= len(src) {
+		return 0, errMalformedJSON
+	}
+
+	switch src[i] {
+	case '"':
+		for j := i + 1; j < len(src); j++ {
+			switch src[j] {
+			case '\\':
+				j++
+			case '"':
+				return j + 1, nil
+			}
+		}
+		return 0, errMalformedJSON
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(src); j++ {
+			switch src[j] {
+			case '"':
+				end, err := scanJSONValue(src, j)
+				if err != nil {
+					return 0, err
+				}
+				j = end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1, nil
+				}
+			}
+		}
+		return 0, errMalformedJSON
+	default:
+		j := i
+		for j < len(src) && !strings.ContainsRune(",}] \t\r\n", rune(src[j])) {
+			j++
+		}
+		if j == i {
+			return 0, errMalformedJSON
+		}
+		return j, nil
+	}
+}
+
+// scanJSONObject locates the members of the object starting at offset start
+func scanJSONObject(src []byte, start int) (jsonObject, error) {
+	i := skipWhitespace(src, start)
+	if i >= len(src) || src[i] != '{' {
+		return jsonObject{}, fmt.Errorf("expected object: %w", errMalformedJSON)
+	}
+	obj := jsonObject{Start: i}
+	i++
+
+	for {
+		i = skipWhitespace(src, i)
+		if i >= len(src) {
+			return jsonObject{}, errMalformedJSON
+		}
+		if src[i] == '}' {
+			obj.End = i + 1
+			return obj, nil
+		}
+		if src[i] == ',' {
+			i++
+			continue
+		}
+
+		keyEnd, err := scanJSONValue(src, i)
+		if err != nil || src[i] != '"' {
+			return jsonObject{}, fmt.Errorf("expected object key: %w", errMalformedJSON)
+		}
+		var key string
+		if err := json.Unmarshal(src[i:keyEnd], &key); err != nil {
+			return jsonObject{}, fmt.Errorf("decode object key: %w", err)
+		}
+
+		colon := skipWhitespace(src, keyEnd)
+		if colon >= len(src) || src[colon] != ':' {
+			return jsonObject{}, fmt.Errorf("expected ':' after %q: %w", key, errMalformedJSON)
+		}
+		valueStart := skipWhitespace(src, colon+1)
+		valueEnd, err := scanJSONValue(src, valueStart)
+		if err != nil {
+			return jsonObject{}, err
+		}
+
+		obj.Members = append(obj.Members, jsonMember{Key: key, KeyStart: i, ValueStart: valueStart, ValueEnd: valueEnd})
+		i = valueEnd
+	}
+}
+
+// lineIndent returns the whitespace at the start of the line containing offset
+func lineIndent(src []byte, offset int) string {
+	lineStart := bytes.LastIndexByte(src[:offset], '\n') + 1
+	end := lineStart
+	for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+		end++
+	}
+	return string(src[lineStart:end])
+}
+
+// detectIndent returns the file's indentation unit, defaulting to 4 spaces
+func detectIndent(src []byte, root jsonObject) string {
+	if len(root.Members) > 0 {
+		if indent := lineIndent(src, root.Members[0].KeyStart); indent != "" {
+			return indent
+		}
+	}
+	return "    "
+}
+
+func encodeJSONString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s) // Encoding a string can't fail
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// setComposerLink adds or updates name: constraint in a link section such as
+// "require" or "require-dev", creating the section if needed. Existing
+// entries keep their position; new ones are appended, or the section is
+// re-sorted when sortPackages is set (config.sort-packages).
+func setComposerLink(src []byte, section, name, constraint string, sortPackages bool) ([]byte, error) {
+	root, err := scanJSONObject(src, 0)
+	if err != nil {
+		return nil, err
+	}
+	indent := detectIndent(src, root)
+
+	sectionMember, ok := root.member(section)
+	if !ok {
+		entry := encodeJSONString(section) + ": {\n" + indent + indent + encodeJSONString(name) + ": " + encodeJSONString(constraint) + "\n" + indent + "}"
+		return insertObjectMember(src, root, indent, entry), nil
+	}
+
+	obj, err := scanJSONObject(src, sectionMember.ValueStart)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", section, err)
+	}
+
+	if existing, ok := obj.member(name); ok {
+		return splice(src, existing.ValueStart, existing.ValueEnd, encodeJSONString(constraint)), nil
+	}
+
+	if sortPackages || len(obj.Members) == 0 {
+		links, err := objectStringMembers(src, obj)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", section, err)
+		}
+		links = append(links, [2]string{name, constraint})
+		if sortPackages {
+			sortLinks(links)
+		}
+		return splice(src, obj.Start, obj.End, formatLinks(links, indent, lineIndent(src, sectionMember.KeyStart))), nil
+	}
+
+	memberIndent := lineIndent(src, obj.Members[0].KeyStart)
+	entry := encodeJSONString(name) + ": " + encodeJSONString(constraint)
+	last := obj.Members[len(obj.Members)-1]
+	return splice(src, last.ValueEnd, last.ValueEnd, ",\n"+memberIndent+entry), nil
+}
+
+// removeComposerLink removes name from a link section, reporting whether it
+// was present. The section itself is kept even when it becomes empty.
+func removeComposerLink(src []byte, section, name string) ([]byte, bool, error) {
+	root, err := scanJSONObject(src, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	sectionMember, ok := root.member(section)
+	if !ok {
+		return src, false, nil
+	}
+	obj, err := scanJSONObject(src, sectionMember.ValueStart)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", section, err)
+	}
+
+	for i, m := range obj.Members {
+		if !strings.EqualFold(m.Key, name) {
+			continue
+		}
+		if len(obj.Members) == 1 {
+			return splice(src, obj.Start, obj.End, "{}"), true, nil
+		}
+		if i == len(obj.Members)-1 {
+			// Drop the preceding comma along with the last member
+			prevEnd := obj.Members[i-1].ValueEnd
+			return splice(src, prevEnd, m.ValueEnd, ""), true, nil
+		}
+		return splice(src, lineStartOrKey(src, m.KeyStart), lineStartOrKey(src, obj.Members[i+1].KeyStart), ""), true, nil
+	}
+	return src, false, nil
+}
+
+// lineStartOrKey returns the start of the line holding a key when the key is
+// the first thing on it, so removing a member takes its line along
+func lineStartOrKey(src []byte, keyStart int) int {
+	lineStart := bytes.LastIndexByte(src[:keyStart], '\n') + 1
+	if strings.TrimSpace(string(src[lineStart:keyStart])) == "" {
+		return lineStart
+	}
+	return keyStart
+}
+
+// insertObjectMember appends a pre-formatted "key": value entry to obj
+func insertObjectMember(src []byte, obj jsonObject, indent, entry string) []byte {
+	if len(obj.Members) == 0 {
+		return splice(src, obj.Start, obj.End, "{\n"+indent+entry+"\n}")
+	}
+	last := obj.Members[len(obj.Members)-1]
+	return splice(src, last.ValueEnd, last.ValueEnd, ",\n"+lineIndent(src, last.KeyStart)+entry)
+}
+
+func objectStringMembers(src []byte, obj jsonObject) ([][2]string, error) {
+	links := make([][2]string, 0, len(obj.Members))
+	for _, m := range obj.Members {
+		var value string
+		if err := json.Unmarshal(src[m.ValueStart:m.ValueEnd], &value); err != nil {
+			return nil, fmt.Errorf("constraint for %s must be a string: %w", m.Key, err)
+		}
+		links = append(links, [2]string{m.Key, value})
+	}
+	return links, nil
+}
+
+func formatLinks(links [][2]string, indent, sectionIndent string) string {
+	var b strings.Builder
+	b.WriteString("{\n")
+	for i, link := range links {
+		b.WriteString(sectionIndent + indent + encodeJSONString(link[0]) + ": " + encodeJSONString(link[1]))
+		if i < len(links)-1 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString(sectionIndent + "}")
+	return b.String()
+}
+
+// sortLinks orders links like Composer's sort-packages: php, hhvm, ext-* and
+// lib-* first, then everything else alphabetically
+func sortLinks(links [][2]string) {
+	key := func(name string) string {
+		name = strings.ToLower(name)
+		if !isPlatformRequirement(name) {
+			return "5-" + name
+		}
+		switch {
+		case strings.HasPrefix(name, "php"):
+			return "0-" + name
+		case strings.HasPrefix(name, "hhvm"):
+			return "1-" + name
+		case strings.HasPrefix(name, "ext"):
+			return "2-" + name
+		case strings.HasPrefix(name, "lib"):
+			return "3-" + name
+		default:
+			return "4-" + name
+		}
+	}
+	sort.SliceStable(links, func(i, j int) bool {
+		return key(links[i][0]) < key(links[j][0])
+	})
+}
+
+func splice(src []byte, start, end int, replacement string) []byte {
+	out := make([]byte, 0, len(src)-(end-start)+len(replacement))
+	out = append(out, src[:start]...)
+	out = append(out, replacement...)
+	return append(out, src[end:]...)
+}
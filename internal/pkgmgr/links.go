@@ -0,0 +1,133 @@
+package pkgmgr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// linkTarget is a name made available by a package's replace or provide
+// link, with the version constraint it is available in
+type linkTarget struct {
+	Constraint string
+	By         string
+	Replace    bool // replace rather than provide
+}
+
+// linkConstraint resolves "self.version" in a link to the owning package's version
+func linkConstraint(constraint, selfVersion string) string {
+	if strings.TrimSpace(constraint) == "self.version" {
+		if selfVersion == "" {
+			return "*"
+		}
+		return selfVersion
+	}
+	return constraint
+}
+
+// collectProviders indexes the names replaced or provided by the root
+// package and every selected package, keyed by lowercase name
+func collectProviders(root Package, selected map[string]Package) map[string][]linkTarget {
+	providers := make(map[string][]linkTarget)
+	add := func(pkg Package, by string) {
+		for name, constraint := range pkg.Replace {
+			name = strings.ToLower(name)
+			providers[name] = append(providers[name], linkTarget{Constraint: linkConstraint(constraint, pkg.Version), By: by, Replace: true})
+		}
+		for name, constraint := range pkg.Provide {
+			name = strings.ToLower(name)
+			providers[name] = append(providers[name], linkTarget{Constraint: linkConstraint(constraint, pkg.Version), By: by})
+		}
+	}
+
+	add(root, rootRequirerName)
+	for _, pkg := range selected {
+		add(pkg, pkg.Name)
+	}
+	return providers
+}
+
+// providerFor returns the package that replaces or provides name in a
+// version compatible with every requirement on it
+func providerFor(providers map[string][]linkTarget, name string, reqs []requirement) (linkTarget, bool) {
+	for _, target := range providers[name] {
+		if target.By == name {
+			continue
+		}
+		ok := true
+		for _, req := range reqs {
+			if !constraintsIntersect(target.Constraint, req.Constraint) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return target, true
+		}
+	}
+	return linkTarget{}, false
+}
+
+// conflictBetween reports whether a declares a conflict with b, either with
+// b itself or with a name b replaces or provides. A package also conflicts
+// with every package it replaces, in any version: they are never installed
+// together.
+func conflictBetween(a, b Package) (string, bool) {
+	if a.Name == b.Name {
+		return "", false
+	}
+
+	for name := range a.Replace {
+		if strings.ToLower(name) == b.Name {
+			return fmt.Sprintf("%s replaces %s, so %s %s cannot be installed alongside it", describePackage(a), b.Name, b.Name, b.Version), true
+		}
+	}
+
+	for name, constraint := range a.Conflict {
+		name = strings.ToLower(name)
+		if name == b.Name && satisfiedBy(b, constraint) {
+			return fmt.Sprintf("%s conflicts with %s %s (%s)", describePackage(a), b.Name, b.Version, constraint), true
+		}
+		for _, links := range []map[string]string{b.Replace, b.Provide} {
+			for linked, linkedConstraint := range links {
+				if strings.ToLower(linked) != name {
+					continue
+				}
+				if constraintsIntersect(linkConstraint(linkedConstraint, b.Version), constraint) {
+					return fmt.Sprintf("%s conflicts with %s (%s), which %s %s replaces or provides", describePackage(a), name, constraint, b.Name, b.Version), true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// conflictsOf returns the conflicts between pkg and the root or any
+// selected package, in either direction, including a package replacing the other
+func conflictsOf(pkg, root Package, selected map[string]Package) []string {
+	var conflicts []string
+	check := func(other Package) {
+		if msg, ok := conflictBetween(pkg, other); ok {
+			conflicts = append(conflicts, msg)
+		}
+		if msg, ok := conflictBetween(other, pkg); ok {
+			conflicts = append(conflicts, msg)
+		}
+	}
+
+	check(root)
+	for _, other := range selected {
+		if other.Name != pkg.Name {
+			check(other)
+		}
+	}
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+func describePackage(pkg Package) string {
+	if pkg.Name == rootRequirerName {
+		return rootRequirerName
+	}
+	return pkg.Name + " " + pkg.Version
+}
@@ -0,0 +1,160 @@
+package pkgmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/julian-richter/PhpResolver/internal/config"
+)
+
+// lockPollInterval is how often a contended lock is retried
+const lockPollInterval = 100 * time.Millisecond
+
+// projectLockName is the lock file guarding a project's vendor directory
+const projectLockName = ".phpResolver.lock"
+
+var errLockTimeout = errors.New("timed out waiting for lock")
+
+func lockTimeout(cfg config.Config) time.Duration {
+	return time.Duration(cfg.Pkgmgr.LockTimeoutSeconds) * time.Second
+}
+
+// cacheLockPath returns the lock file guarding a single cache entry
+func cacheLockPath(archivePath string) string {
+	return archivePath + ".lock"
+}
+
+// acquireProjectLock takes the exclusive project-level lock on vendorDir so
+// parallel runs in the same project never modify vendor/ concurrently
+func acquireProjectLock(ctx context.Context, vendorDir string, cfg config.Config, logger *log.Logger) (*fileLock, error) {
+	lock, err := acquireFileLock(ctx, filepath.Join(vendorDir, projectLockName), true, lockTimeout(cfg), logger)
+	if err != nil {
+		return nil, fmt.Errorf("acquire vendor lock: %w", err)
+	}
+	return lock, nil
+}
+
+// fileLock is an advisory lock held on a lock file. Locks are cooperative:
+// they only exclude other phpResolver processes, not arbitrary writers.
+type fileLock struct {
+	file *os.File
+	path string
+}
+
+// acquireFileLock blocks until the advisory lock on path is obtained, the
+// timeout elapses or ctx is cancelled. Exclusive holders record their PID in
+// the lock file so waiting processes can report who they are waiting for.
+func acquireFileLock(ctx context.Context, path string, exclusive bool, timeout time.Duration, logger *log.Logger) (*fileLock, error) {
+	deadline := time.Now().Add(timeout)
+	waitingLogged := false
+
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open lock file %s: %w", path, err)
+		}
+
+		locked, err := tryLockFile(file, exclusive)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+
+		if locked {
+			// The lock file may have been removed and recreated by its previous
+			// holder (e.g. cache gc) between our open and lock; if so our lock
+			// is on an orphaned inode and we must start over.
+			if sameLockFile(file, path) {
+				lock := &fileLock{file: file, path: path}
+				if exclusive {
+					lock.writePID()
+				}
+				return lock, nil
+			}
+			unlockFile(file)
+			file.Close()
+			continue
+		}
+
+		if !waitingLogged {
+			if pid := readLockPID(file); pid > 0 {
+				logger.Info("Waiting for lock held by PID "+strconv.Itoa(pid), "path", path, "timeout", timeout)
+			} else {
+				logger.Info("Waiting for lock", "path", path, "timeout", timeout)
+			}
+			waitingLogged = true
+		}
+		file.Close()
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s after %s: %w", path, timeout, errLockTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// Release unlocks and closes the lock file. The file itself is left in place
+// so concurrent waiters keep contending on the same inode.
+func (l *fileLock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	unlockFile(l.file)
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// ReleaseAndRemove deletes the lock file while still holding the lock, then
+// releases it. Waiters notice the inode change and retry on a fresh file.
+func (l *fileLock) ReleaseAndRemove() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		l.Release()
+		return fmt.Errorf("remove lock file: %w", err)
+	}
+	return l.Release()
+}
+
+func (l *fileLock) writePID() {
+	if err := l.file.Truncate(0); err != nil {
+		return
+	}
+	_, _ = l.file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+}
+
+func readLockPID(file *os.File) int {
+	buf := make([]byte, 32)
+	n, _ := file.ReadAt(buf, 0)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(buf[:n])))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+func sameLockFile(file *os.File, path string) bool {
+	held, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(held, current)
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package pkgmgr
+
+import "os"
+
+// tryLockFile is a no-op on platforms without flock; concurrent runs are not
+// protected from each other there
+func tryLockFile(file *os.File, exclusive bool) (bool, error) {
+	return true, nil
+}
+
+func unlockFile(file *os.File) {}
@@ -0,0 +1,471 @@
+package pkgmgr
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	lockFileName      = "composer.lock"
+	installedJSONName = "installed.json"
+)
+
+// lockReadme is the explanatory header Composer puts in every composer.lock
+var lockReadme = []string{
+	"This file locks the dependencies of your project to a known state",
+	"Read more about it at https://getcomposer.org/doc/01-basic-usage.md#installing-dependencies",
+	"This file is @generated automatically",
+}
+
+// lockFile is composer.lock, with fields in Composer's order
+type lockFile struct {
+	Readme           []string          `json:"_readme"`
+	ContentHash      string            `json:"content-hash"`
+	Packages         []packageMetadata `json:"packages"`
+	PackagesDev      []packageMetadata `json:"packages-dev"`
+	Aliases          []lockAlias       `json:"aliases"`
+	MinimumStability string            `json:"minimum-stability"`
+	StabilityFlags   map[string]int    `json:"stability-flags"`
+	PreferStable     bool              `json:"prefer-stable"`
+	PreferLowest     bool              `json:"prefer-lowest"`
+	Platform         map[string]string `json:"platform"`
+	PlatformDev      map[string]string `json:"platform-dev"`
+	PluginAPIVersion string            `json:"plugin-api-version"`
+}
+
+// installedJSON is vendor/composer/installed.json, the record of what is
+// currently installed in vendor/
+type installedJSON struct {
+	Packages        []packageMetadata `json:"packages"`
+	Dev             bool              `json:"dev"`
+	DevPackageNames []string          `json:"dev-package-names"`
+}
+
+// lockFilePath returns the composer.lock path next to composer.json
+func lockFilePath(composerPath string) string {
+	return filepath.Join(filepath.Dir(composerPath), lockFileName)
+}
+
+// installedJSONPath returns the path of vendor/composer/installed.json
+func installedJSONPath(vendorDir string) string {
+	return filepath.Join(vendorDir, "composer", installedJSONName)
+}
+
+func readLockFile(path string) (*lockFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", lockFileName, err)
+	}
+
+	var lock lockFile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", lockFileName, err)
+	}
+	return &lock, nil
+}
+
+// newLockFile records the resolved packages for the project whose raw
+// composer.json is composerData
+func newLockFile(composerData []byte, composer ComposerJSON, packages []Package) (*lockFile, error) {
+	hash, err := composerContentHash(composerData)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &lockFile{
+		Readme:           lockReadme,
+		ContentHash:      hash,
+		Packages:         make([]packageMetadata, 0, len(packages)),
+		PackagesDev:      []packageMetadata{},
+		Aliases:          lockAliases(rootRequirements(composer), packages),
+		MinimumStability: strings.ToLower(composer.MinimumStability.String()),
+		StabilityFlags:   make(map[string]int),
+		PreferStable:     composer.PreferStable,
+		Platform:         make(map[string]string),
+		PlatformDev:      make(map[string]string),
+		PluginAPIVersion: composerPluginAPIVersion,
+	}
+
+	dev := make(map[string]bool)
+	for _, name := range devPackageNames(composer, packages) {
+		dev[name] = true
+	}
+
+	sorted := make([]Package, len(packages))
+	copy(sorted, packages)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	for _, pkg := range sorted {
+		meta := packageToMetadata(pkg)
+		meta.VersionNormalized = "" // Only installed.json carries normalized versions
+		if dev[pkg.Name] {
+			lock.PackagesDev = append(lock.PackagesDev, meta)
+		} else {
+			lock.Packages = append(lock.Packages, meta)
+		}
+	}
+
+	for name, flag := range rootStabilityFlags(rootRequirements(composer)) {
+		lock.StabilityFlags[name] = flag.rank()
+	}
+	for name, constraint := range composer.Require {
+		if isPlatformRequirement(name) {
+			lock.Platform[strings.ToLower(name)] = constraint
+		}
+	}
+	for name, constraint := range composer.RequireDev {
+		if isPlatformRequirement(name) {
+			lock.PlatformDev[strings.ToLower(name)] = constraint
+		}
+	}
+
+	return lock, nil
+}
+
+// devPackageNames returns the sorted names of the packages only needed
+// through require-dev: those not reachable from the root's require links,
+// directly or via a package that replaces or provides the required name
+func devPackageNames(composer ComposerJSON, packages []Package) []string {
+	byName := make(map[string]Package, len(packages))
+	for _, pkg := range packages {
+		byName[pkg.Name] = pkg
+	}
+	providers := make(map[string][]string)
+	for _, pkg := range packages {
+		for _, links := range []map[string]string{pkg.Replace, pkg.Provide} {
+			for name := range links {
+				name = strings.ToLower(name)
+				providers[name] = append(providers[name], pkg.Name)
+			}
+		}
+	}
+
+	reachable := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		name = strings.ToLower(name)
+		if isPlatformRequirement(name) {
+			return
+		}
+		targets := providers[name]
+		if _, ok := byName[name]; ok {
+			targets = append([]string{name}, targets...)
+		}
+		for _, target := range targets {
+			if reachable[target] {
+				continue
+			}
+			reachable[target] = true
+			for dep := range byName[target].Require {
+				visit(dep)
+			}
+		}
+	}
+	for name := range composer.Require {
+		visit(name)
+	}
+
+	var dev []string
+	for _, pkg := range packages {
+		if !reachable[pkg.Name] {
+			dev = append(dev, pkg.Name)
+		}
+	}
+	sort.Strings(dev)
+	return dev
+}
+
+func writeLockFile(path string, lock *lockFile) error {
+	return writeJSONFile(path, lock)
+}
+
+// writeProjectLock writes composer.lock next to composerPath for the
+// resolved packages
+func writeProjectLock(composerPath string, composer ComposerJSON, packages []Package, logger *log.Logger) error {
+	data, err := os.ReadFile(composerPath)
+	if err != nil {
+		return fmt.Errorf("read composer.json: %w", err)
+	}
+
+	lock, err := newLockFile(data, composer, packages)
+	if err != nil {
+		return err
+	}
+
+	path := lockFilePath(composerPath)
+	if err := writeLockFile(path, lock); err != nil {
+		return err
+	}
+	logger.Info("Wrote lock file", "path", path, "packages", len(lock.Packages), "aliases", len(lock.Aliases))
+	return nil
+}
+
+// composerContentHash computes composer.lock's content-hash the way Composer
+// does: the md5 of PHP's json_encode of the relevant composer.json keys
+func composerContentHash(composerData []byte) (string, error) {
+	var content map[string]json.RawMessage
+	if err := json.Unmarshal(composerData, &content); err != nil {
+		return "", fmt.Errorf("parse composer.json for content hash: %w", err)
+	}
+
+	relevant := make(map[string]json.RawMessage)
+	for _, key := range []string{"name", "version", "require", "require-dev", "conflict", "replace", "provide", "minimum-stability", "prefer-stable", "repositories", "extra"} {
+		if raw, ok := content[key]; ok {
+			relevant[key] = raw
+		}
+	}
+	if rawConfig, ok := content["config"]; ok {
+		var cfg map[string]json.RawMessage
+		if err := json.Unmarshal(rawConfig, &cfg); err == nil {
+			if platform, ok := cfg["platform"]; ok {
+				relevant["config"] = json.RawMessage(`{"platform":` + string(platform) + `}`)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(relevant))
+	for key := range relevant {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(phpJSONString(key))
+		buf.WriteByte(':')
+		if err := phpJSONEncode(&buf, relevant[key]); err != nil {
+			return "", fmt.Errorf("encode composer.json %s for content hash: %w", key, err)
+		}
+	}
+	buf.WriteByte('}')
+
+	sum := md5.Sum(buf.Bytes())
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// phpJSONEncode re-encodes raw JSON the way PHP's json_encode would after
+// json_decode($raw, true): compact, key order preserved, slashes and
+// non-ASCII characters escaped and empty objects turned into [].
+func phpJSONEncode(buf *bytes.Buffer, raw json.RawMessage) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	type frame struct {
+		object bool
+		n      int
+	}
+	var stack []frame
+
+	separate := func() {
+		if len(stack) == 0 {
+			return
+		}
+		f := &stack[len(stack)-1]
+		switch {
+		case f.object && f.n%2 == 1:
+			buf.WriteByte(':')
+		case f.n > 0:
+			buf.WriteByte(',')
+		}
+		f.n++
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{', '[':
+				separate()
+				buf.WriteByte(byte(t))
+				stack = append(stack, frame{object: t == '{'})
+			case '}':
+				if stack[len(stack)-1].n == 0 {
+					// PHP decodes {} to an empty array and encodes it back as []
+					buf.Truncate(buf.Len() - 1)
+					buf.WriteString("[]")
+				} else {
+					buf.WriteByte('}')
+				}
+				stack = stack[:len(stack)-1]
+			case ']':
+				buf.WriteByte(']')
+				stack = stack[:len(stack)-1]
+			}
+		case string:
+			separate()
+			buf.WriteString(phpJSONString(t))
+		case json.Number:
+			separate()
+			buf.WriteString(t.String())
+		case bool:
+			separate()
+			buf.WriteString(strconv.FormatBool(t))
+		case nil:
+			separate()
+			buf.WriteString("null")
+		}
+	}
+}
+
+// phpJSONString quotes s like json_encode without flags
+func phpJSONString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '/':
+			b.WriteString(`\/`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			switch {
+			case r < 0x20:
+				fmt.Fprintf(&b, `\u%04x`, r)
+			case r < 0x80:
+				b.WriteRune(r)
+			case r > 0xffff:
+				r1, r2 := utf16.EncodeRune(r)
+				fmt.Fprintf(&b, `\u%04x\u%04x`, r1, r2)
+			default:
+				fmt.Fprintf(&b, `\u%04x`, r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// allPackages returns the locked packages including dev packages
+func (l *lockFile) allPackages() []Package {
+	packages := make([]Package, 0, len(l.Packages)+len(l.PackagesDev))
+	for _, meta := range l.Packages {
+		packages = append(packages, meta.toPackage())
+	}
+	for _, meta := range l.PackagesDev {
+		packages = append(packages, meta.toPackage())
+	}
+	return packages
+}
+
+// readInstalledJSON reads vendor/composer/installed.json. Both the Composer 2
+// format ({"packages": [...]}) and the Composer 1 format (a bare array) are accepted.
+func readInstalledJSON(vendorDir string) (*installedJSON, error) {
+	data, err := os.ReadFile(installedJSONPath(vendorDir))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", installedJSONName, err)
+	}
+
+	var installed installedJSON
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &installed.Packages); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", installedJSONName, err)
+		}
+		return &installed, nil
+	}
+
+	if err := json.Unmarshal(data, &installed); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", installedJSONName, err)
+	}
+	return &installed, nil
+}
+
+func (i *installedJSON) allPackages() []Package {
+	packages := make([]Package, 0, len(i.Packages))
+	for _, meta := range i.Packages {
+		packages = append(packages, meta.toPackage())
+	}
+	return packages
+}
+
+// writeInstalledJSON records the packages now present in vendor/
+func writeInstalledJSON(vendorDir string, packages []Package, devNames []string) error {
+	installed := installedJSON{
+		Packages:        make([]packageMetadata, 0, len(packages)),
+		Dev:             true,
+		DevPackageNames: append([]string{}, devNames...),
+	}
+	for _, pkg := range packages {
+		meta := packageToMetadata(pkg)
+		meta.InstallPath = "../" + pkg.Name
+		meta.InstallationSource = installationSource(pkg)
+		installed.Packages = append(installed.Packages, meta)
+	}
+
+	return writeJSONFile(installedJSONPath(vendorDir), installed)
+}
+
+// writeJSONFile atomically writes v as Composer-style pretty-printed JSON
+// (4-space indent, unescaped slashes and unicode)
+func writeJSONFile(path string, v any) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("encode %s: %w", filepath.Base(path), err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create dir for %s: %w", filepath.Base(path), err)
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	tempPath := tempFile.Name()
+
+	if _, err := tempFile.Write(buf.Bytes()); err != nil {
+		tempFile.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+	if err := tempFile.Close(); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("close %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Chmod(tempPath, 0o644); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("set %s permissions: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("rename %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
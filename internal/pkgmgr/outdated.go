@@ -0,0 +1,277 @@
+package pkgmgr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/log"
+	"github.com/julian-richter/PhpResolver/internal/config"
+)
+
+// Update kinds reported by outdated, from the locked to the latest version
+const (
+	updatePatch = "patch" // same major and minor version
+	updateMinor = "minor" // semver-compatible, within the caret range of the locked version
+	updateMajor = "major" // breaking, or not comparable (dev branches)
+)
+
+// OutdatedOptions holds command-line options for outdated
+type OutdatedOptions struct {
+	Direct             bool   // --direct: only packages required by composer.json
+	MinorOnly          bool   // --minor-only: only consider semver-compatible updates
+	Strict             bool   // --strict: fail when any package is outdated
+	Format             string // --format: "text" (default) or "json"
+	IgnorePlatformReqs bool   // --ignore-platform-reqs
+}
+
+// outdatedPackage compares a locked package with the newest versions available
+type outdatedPackage struct {
+	Name         string `json:"name"`
+	Direct       bool   `json:"direct-dependency"`
+	Version      string `json:"version"`
+	Wanted       string `json:"wanted,omitempty"` // newest version every constraint in the lock allows
+	Latest       string `json:"latest"`
+	Update       string `json:"update"`
+	LatestStatus string `json:"latest-status"` // Composer's semver-safe-update or update-possible
+	Description  string `json:"description,omitempty"`
+}
+
+// RunOutdated lists locked packages with newer versions, showing both the
+// newest version the project's constraints allow and the newest overall.
+// With --strict an error is returned if any package is outdated.
+func RunOutdated(ctx context.Context, logger *log.Logger, cfg config.Config, opts OutdatedOptions) error {
+	switch opts.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("invalid format %q (must be text or json)", opts.Format)
+	}
+
+	composerPath, err := FindComposerJSON(".")
+	if err != nil {
+		return fmt.Errorf("find composer.json: %w", err)
+	}
+	composer, err := ParseComposerJSON(composerPath)
+	if err != nil {
+		return fmt.Errorf("parse composer.json: %w", err)
+	}
+
+	lock, err := readLockFile(lockFilePath(composerPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no %s found next to %s (run update first)", lockFileName, composerPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	client, err := newProjectHTTPClient(filepath.Dir(composerPath), composer, cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	ropts := resolveOptions(composer, cfg, InstallOptions{IgnorePlatformReqs: opts.IgnorePlatformReqs})
+	results, err := findOutdated(ctx, client, composer, lock.allPackages(), ropts, opts, logger)
+	if err != nil {
+		return err
+	}
+
+	if opts.Format == "json" {
+		err = writeOutdatedJSON(os.Stdout, results)
+	} else if len(results) > 0 {
+		err = writeOutdatedTable(os.Stdout, results, colorOutput(os.Stdout))
+	}
+	if err != nil {
+		return fmt.Errorf("write results: %w", err)
+	}
+
+	if len(results) == 0 {
+		logger.Info("All packages are up to date")
+		return nil
+	}
+	if opts.Strict {
+		return fmt.Errorf("%d package(s) are outdated", len(results))
+	}
+	return nil
+}
+
+// findOutdated looks up the newest versions of each locked package and
+// returns the outdated ones sorted by name
+func findOutdated(ctx context.Context, client *HTTPClient, composer ComposerJSON, locked []Package, ropts ResolveOptions, opts OutdatedOptions, logger *log.Logger) ([]outdatedPackage, error) {
+	rootRequire := rootRequirements(composer)
+	direct := make(map[string]bool, len(rootRequire))
+	for name := range rootRequire {
+		direct[strings.ToLower(name)] = true
+	}
+
+	selected := make(map[string]Package, len(locked))
+	var names []string
+	for _, pkg := range locked {
+		selected[pkg.Name] = pkg
+		if !opts.Direct || direct[pkg.Name] {
+			names = append(names, pkg.Name)
+		}
+	}
+	sort.Strings(names)
+
+	r := newResolver(client, rootRequire, ropts, logger)
+	if err := r.prefetch(ctx, names); err != nil {
+		return nil, err
+	}
+	reqs := collectRequirements(rootRequire, selected)
+
+	var results []outdatedPackage
+	for _, name := range names {
+		pkg := selected[name]
+
+		// The newest version overall, or with --minor-only the newest within
+		// the caret range of the locked version. Like Composer, prefer-stable
+		// looks for versions at least as stable as the locked one, so a
+		// locked pre-release is compared with newer pre-releases rather
+		// than with an older stable release.
+		latestConstraint := "*"
+		if opts.MinorOnly {
+			latestConstraint = "^" + pkg.Version
+		}
+		stability := r.allowedStability(name)
+		if ropts.PreferStable {
+			stability = versionStability(pkg.Version)
+		}
+		latest, err := r.newestVersion(ctx, name, latestConstraint, stability)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			logger.Debug("No newer version found", "package", name, "error", err)
+			continue
+		}
+		if compareVersions(latest.Version, pkg.Version) <= 0 {
+			continue
+		}
+
+		others := make(map[string]Package, len(selected))
+		for other, p := range selected {
+			if other != name {
+				others[other] = p
+			}
+		}
+		var wanted string
+		if match, err := r.selectVersion(ctx, name, reqs[name], others); err == nil {
+			wanted = match.Version
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		update := updateKind(pkg.Version, latest.Version)
+		status := "update-possible"
+		if update != updateMajor {
+			status = "semver-safe-update"
+		}
+		results = append(results, outdatedPackage{
+			Name:         name,
+			Direct:       direct[name],
+			Version:      pkg.Version,
+			Wanted:       wanted,
+			Latest:       latest.Version,
+			Update:       update,
+			LatestStatus: status,
+			Description:  pkg.Description,
+		})
+	}
+	return results, nil
+}
+
+// newestVersion returns the newest installable version of name that matches
+// constraint and is at least as stable as stability, regardless of
+// prefer-stable
+func (r *resolver) newestVersion(ctx context.Context, name, constraint string, stability Stability) (Package, error) {
+	versions, err := r.packageVersions(ctx, name)
+	if err != nil {
+		return Package{}, err
+	}
+	for _, pkg := range versions {
+		if !r.installable(pkg) || !satisfiedBy(pkg, constraint) || !stability.allows(versionStability(pkg.Version)) {
+			continue
+		}
+		if len(conflictsOf(pkg, r.root, nil)) > 0 {
+			continue
+		}
+		if err := r.checkPackagePlatform(ctx, pkg); err != nil {
+			var unmet platformError
+			if ctx.Err() != nil || !errors.As(err, &unmet) {
+				return Package{}, err
+			}
+			continue
+		}
+		return pkg, nil
+	}
+	return Package{}, fmt.Errorf("no installable %s version matches %s at stability %s", name, constraint, stability)
+}
+
+// updateKind classifies the step from the locked to the latest version
+func updateKind(current, latest string) string {
+	currentParts := strings.SplitN(normalizeVersion(current), ".", 3)
+	latestParts := strings.SplitN(normalizeVersion(latest), ".", 3)
+	if versionStability(current) != StabilityDev && len(currentParts) == 3 && len(latestParts) == 3 &&
+		currentParts[0] == latestParts[0] && currentParts[1] == latestParts[1] {
+		return updatePatch
+	}
+	if versionStability(current) != StabilityDev && versionSatisfies(latest, "^"+current) {
+		return updateMinor
+	}
+	return updateMajor
+}
+
+// ANSI colors for the update kinds
+var updateColors = map[string]string{
+	updatePatch: "\x1b[32m", // green
+	updateMinor: "\x1b[33m", // yellow
+	updateMajor: "\x1b[31m", // red
+}
+
+// colorOutput reports whether f is a terminal and NO_COLOR is unset
+func colorOutput(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func writeOutdatedTable(w io.Writer, results []outdatedPackage, color bool) error {
+	// Escape codes are invisible but tabwriter counts them, so every cell of
+	// a colored column, the header included, gets codes of the same length
+	paint := func(code, text string) string {
+		if !color {
+			return text
+		}
+		return code + text + "\x1b[0m"
+	}
+	const defaultColor = "\x1b[39m"
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "PACKAGE\tVERSION\tWANTED\t%s\t%s\tDESCRIPTION\n", paint(defaultColor, "LATEST"), paint(defaultColor, "UPDATE"))
+	for _, r := range results {
+		wanted := valueOr(r.Wanted, "n/a")
+		latest := paint(updateColors[r.Update], r.Latest)
+		update := paint(updateColors[r.Update], r.Update)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Name, r.Version, wanted, latest, update, r.Description)
+	}
+	return tw.Flush()
+}
+
+func writeOutdatedJSON(w io.Writer, results []outdatedPackage) error {
+	if results == nil {
+		results = []outdatedPackage{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	return enc.Encode(map[string][]outdatedPackage{"installed": results})
+}
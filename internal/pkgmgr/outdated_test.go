@@ -0,0 +1,44 @@
+package pkgmgr
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/charmbracelet/log"
+)
+
+func TestFindOutdatedPreferStableKeepsLockedStability(t *testing.T) {
+	client, ropts := newTestRepository(t, map[string]string{
+		"acme/lib": `{"package": {"versions": {
+			"1.9.5": {"dist": {"url": "SRV/lib-1.9.5.zip", "type": "zip"}},
+			"2.0.0-beta3": {"dist": {"url": "SRV/lib-2.0.0-beta3.zip", "type": "zip"}}
+		}}}`,
+		"acme/tool": `{"package": {"versions": {
+			"1.0.0": {"dist": {"url": "SRV/tool-1.0.0.zip", "type": "zip"}},
+			"1.1.0-beta1": {"dist": {"url": "SRV/tool-1.1.0-beta1.zip", "type": "zip"}},
+			"1.1.0-beta2": {"dist": {"url": "SRV/tool-1.1.0-beta2.zip", "type": "zip"}}
+		}}}`,
+	})
+	ropts.PreferStable = true
+
+	composer := ComposerJSON{
+		Require:      map[string]string{"acme/lib": "^2.0@beta", "acme/tool": "^1.1@beta"},
+		PreferStable: true,
+	}
+	locked := []Package{
+		{Name: "acme/lib", Version: "2.0.0-beta3"},
+		{Name: "acme/tool", Version: "1.1.0-beta1"},
+	}
+
+	results, err := findOutdated(context.Background(), client, composer, locked, ropts, OutdatedOptions{}, log.New(io.Discard))
+	if err != nil {
+		t.Fatalf("findOutdated: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("findOutdated returned %+v, want only acme/tool", results)
+	}
+	if got := results[0]; got.Name != "acme/tool" || got.Latest != "1.1.0-beta2" || got.Update != updatePatch {
+		t.Errorf("findOutdated returned %+v, want acme/tool 1.1.0-beta1 -> 1.1.0-beta2 (patch)", got)
+	}
+}
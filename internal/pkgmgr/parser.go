@@ -39,7 +39,9 @@ func ParseComposerJSON(path string) (ComposerJSON, error) {
 	return composer, nil
 }
 
-func GenerateAutoloader(ctx context.Context, autoload Autoload, vendorDir string, logger *log.Logger) error {
+// GenerateAutoloader writes vendor/autoload.php. With includePlatformCheck the
+// autoloader first requires vendor/composer/platform_check.php.
+func GenerateAutoloader(ctx context.Context, autoload Autoload, vendorDir string, includePlatformCheck bool, logger *log.Logger) error {
 	logger.Info("Generating autoloader (MVP)", "psr4_count", len(autoload.PSR4), "psr0_count", len(autoload.PSR0), "classmap_count", len(autoload.Classmap), "files_count", len(autoload.Files))
 
 	// MVP: Create basic autoloader stub with configuration info
@@ -82,8 +84,12 @@ func GenerateAutoloader(ctx context.Context, autoload Autoload, vendorDir string
 		}
 	}
 
-	phpContent.WriteString(`//
-// TODO: Implement actual PSR-4, PSR-0, classmap, and files autoloading
+	phpContent.WriteString("//\n")
+	if includePlatformCheck {
+		phpContent.WriteString("require __DIR__ . '/composer/" + platformCheckFileName + "';\n\n")
+	}
+
+	phpContent.WriteString(`// TODO: Implement actual PSR-4, PSR-0, classmap, and files autoloading
 echo "phpResolver autoloader loaded (MVP - configuration detected but not implemented)\n";
 `)
 
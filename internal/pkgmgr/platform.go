@@ -0,0 +1,263 @@
+package pkgmgr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+)
+
+// Versions of the Composer APIs phpResolver stands in for. Packages such as
+// Composer plugins require these; plugins themselves are never executed.
+const (
+	composerVersion           = "2.8.0"
+	composerPluginAPIVersion  = "2.6.0"
+	composerRuntimeAPIVersion = "2.2.2"
+)
+
+var (
+	platformRequirementRE = regexp.MustCompile(`(?i)^(?:php(?:-64bit|-ipv6|-zts|-debug)?|hhvm|(?:ext|lib)-[a-z0-9](?:[_.-]?[a-z0-9]+)*|composer(?:-plugin-api|-runtime-api)?)$`)
+	leadingVersionRE      = regexp.MustCompile(`^v?(\d+(?:\.\d+)*)`)
+)
+
+// platformDetectScript prints the running PHP's version, extensions and
+// bundled library versions as JSON, using Composer's naming for each
+const platformDetectScript = `
+$exts = [];
+foreach (get_loaded_extensions() as $e) {
+    $v = phpversion($e);
+    $exts[$e] = $v === false ? '0' : $v;
+}
+$libs = [];
+if (defined('OPENSSL_VERSION_TEXT') && preg_match('{^(?:OpenSSL|LibreSSL)?\s*([0-9.]+)}i', OPENSSL_VERSION_TEXT, $m)) { $libs['openssl'] = $m[1]; }
+if (function_exists('curl_version')) { $c = curl_version(); $libs['curl'] = $c['version']; }
+if (defined('LIBXML_DOTTED_VERSION')) { $libs['libxml'] = LIBXML_DOTTED_VERSION; }
+if (defined('PCRE_VERSION')) { $libs['pcre'] = explode(' ', PCRE_VERSION)[0]; }
+if (defined('INTL_ICU_VERSION')) { $libs['icu'] = INTL_ICU_VERSION; }
+if (defined('ZLIB_VERSION')) { $libs['zlib'] = ZLIB_VERSION; }
+if (defined('ICONV_VERSION')) { $libs['iconv'] = ICONV_VERSION; }
+if (defined('SODIUM_LIBRARY_VERSION')) { $libs['libsodium'] = SODIUM_LIBRARY_VERSION; }
+if (defined('LIBXSLT_DOTTED_VERSION')) { $libs['libxslt'] = LIBXSLT_DOTTED_VERSION; }
+if (defined('GD_VERSION')) { $libs['gd'] = GD_VERSION; }
+echo json_encode([
+    'php' => PHP_MAJOR_VERSION . '.' . PHP_MINOR_VERSION . '.' . PHP_RELEASE_VERSION,
+    'bits' => PHP_INT_SIZE * 8,
+    'zts' => (bool) PHP_ZTS,
+    'debug' => (bool) PHP_DEBUG,
+    'ipv6' => defined('AF_INET6'),
+    'extensions' => (object) $exts,
+    'libs' => (object) $libs,
+]);
+`
+
+// Platform is the set of virtual platform packages (php, ext-*, lib-*, ...)
+// available locally, keyed by lowercase name
+type Platform struct {
+	Packages map[string]string
+}
+
+// Version returns the installed version of a platform package
+func (p *Platform) Version(name string) (string, bool) {
+	if p == nil {
+		return "", false
+	}
+	v, ok := p.Packages[strings.ToLower(name)]
+	return v, ok
+}
+
+// platformCache holds detection results per PHP binary so php is only
+// invoked once per process
+var platformCache = struct {
+	sync.Mutex
+	byBinary map[string]*Platform
+}{byBinary: make(map[string]*Platform)}
+
+// DetectPlatform invokes phpBinary once to learn the local PHP version,
+// loaded extensions and library versions. Results are cached per binary.
+func DetectPlatform(ctx context.Context, phpBinary string, logger *log.Logger) (*Platform, error) {
+	platformCache.Lock()
+	defer platformCache.Unlock()
+
+	if p, ok := platformCache.byBinary[phpBinary]; ok {
+		return p, nil
+	}
+
+	path, err := exec.LookPath(phpBinary)
+	if err != nil {
+		return nil, fmt.Errorf("find php binary %q (use --ignore-platform-reqs to skip platform checks): %w", phpBinary, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, "-r", platformDetectScript)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("run %s to detect platform: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var detected struct {
+		PHP        string            `json:"php"`
+		Bits       int               `json:"bits"`
+		ZTS        bool              `json:"zts"`
+		Debug      bool              `json:"debug"`
+		IPv6       bool              `json:"ipv6"`
+		Extensions map[string]string `json:"extensions"`
+		Libs       map[string]string `json:"libs"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &detected); err != nil {
+		return nil, fmt.Errorf("parse platform detection output: %w", err)
+	}
+
+	p := &Platform{Packages: map[string]string{
+		"php":                  detected.PHP,
+		"composer":             composerVersion,
+		"composer-plugin-api":  composerPluginAPIVersion,
+		"composer-runtime-api": composerRuntimeAPIVersion,
+	}}
+	if detected.Bits == 64 {
+		p.Packages["php-64bit"] = detected.PHP
+	}
+	if detected.ZTS {
+		p.Packages["php-zts"] = detected.PHP
+	}
+	if detected.Debug {
+		p.Packages["php-debug"] = detected.PHP
+	}
+	if detected.IPv6 {
+		p.Packages["php-ipv6"] = detected.PHP
+	}
+	for name, version := range detected.Extensions {
+		p.Packages["ext-"+platformPackageName(name)] = normalizePlatformVersion(version)
+	}
+	for name, version := range detected.Libs {
+		p.Packages["lib-"+platformPackageName(name)] = normalizePlatformVersion(version)
+	}
+
+	logger.Debug("Detected platform", "php", detected.PHP, "binary", path, "extensions", len(detected.Extensions), "libs", len(detected.Libs))
+	platformCache.byBinary[phpBinary] = p
+	return p, nil
+}
+
+// platformPackageName converts an extension name as reported by PHP
+// (e.g. "Zend OPcache") to Composer's package naming ("zend-opcache")
+func platformPackageName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "-")
+}
+
+// normalizePlatformVersion keeps the leading numeric part of versions such as
+// "8.3.6-1ubuntu1" or "1.2.3-dev"; unversioned packages become "0"
+func normalizePlatformVersion(version string) string {
+	if m := leadingVersionRE.FindStringSubmatch(strings.TrimSpace(version)); m != nil {
+		return m[1]
+	}
+	return "0"
+}
+
+func isPlatformRequirement(name string) bool {
+	return platformRequirementRE.MatchString(name)
+}
+
+// PlatformOptions controls which platform requirements are enforced and
+// which platform package versions are assumed
+type PlatformOptions struct {
+	PHPBinary  string
+	IgnoreAll  bool              // --ignore-platform-reqs
+	IgnoreReqs []string          // --ignore-platform-req, supports trailing * wildcards
+	Overrides  PlatformOverrides // composer.json config.platform
+}
+
+// ignores reports whether the requirement on name should not be enforced
+func (o PlatformOptions) ignores(name string) bool {
+	if o.IgnoreAll {
+		return true
+	}
+	name = strings.ToLower(name)
+	for _, pattern := range o.IgnoreReqs {
+		pattern = strings.ToLower(pattern)
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// platformError marks a requirement the platform cannot satisfy, as opposed
+// to a failure to detect the platform at all
+type platformError struct {
+	err error
+}
+
+func (e platformError) Error() string { return e.err.Error() }
+func (e platformError) Unwrap() error { return e.err }
+
+// platformView answers platform package queries from config.platform
+// overrides first and the detected local platform second. php is only
+// invoked for queries that no override covers.
+type platformView struct {
+	opts   PlatformOptions
+	logger *log.Logger
+}
+
+func newPlatformView(opts PlatformOptions, logger *log.Logger) *platformView {
+	for name, version := range opts.Overrides {
+		if version == "" {
+			logger.Debug("Platform package disabled by config.platform", "package", name)
+		} else {
+			logger.Debug("Using config.platform override", "package", name, "version", version)
+		}
+	}
+	return &platformView{opts: opts, logger: logger}
+}
+
+// Version returns the version of a platform package and whether it is present
+func (v *platformView) Version(ctx context.Context, name string) (string, bool, error) {
+	name = strings.ToLower(name)
+
+	if version, ok := v.opts.Overrides[name]; ok {
+		return version, version != "", nil
+	}
+
+	detected, err := DetectPlatform(ctx, v.opts.PHPBinary, v.logger)
+	if err != nil {
+		return "", false, err
+	}
+	version, ok := detected.Version(name)
+	if !ok {
+		return "", false, nil
+	}
+
+	// Overriding php also moves the php-64bit/-ipv6/-zts/-debug variants the
+	// local PHP provides, so they can't contradict the overridden version
+	if strings.HasPrefix(name, "php-") {
+		if php, ok := v.opts.Overrides["php"]; ok && php != "" {
+			return php, true, nil
+		}
+	}
+	return version, true, nil
+}
+
+// check verifies a single platform requirement, returning a platformError
+// if it is not met
+func (v *platformView) check(ctx context.Context, name, constraint string) error {
+	version, ok, err := v.Version(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return platformError{fmt.Errorf("%s is missing from your system", name)}
+	}
+	if !versionSatisfies(version, constraint) {
+		return platformError{fmt.Errorf("%s %s does not satisfy %s", name, version, constraint)}
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package pkgmgr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+const platformCheckFileName = "platform_check.php"
+
+var zendExtensionMap = map[string]string{"zend-opcache": "zend opcache"}
+
+// PlatformCheckMode is composer.json's config.platform-check: true, false or "php-only"
+type PlatformCheckMode string
+
+const (
+	PlatformCheckEnabled  PlatformCheckMode = "true"
+	PlatformCheckDisabled PlatformCheckMode = "false"
+	PlatformCheckPHPOnly  PlatformCheckMode = "php-only"
+)
+
+func (m *PlatformCheckMode) UnmarshalJSON(data []byte) error {
+	switch strings.TrimSpace(string(data)) {
+	case "true":
+		*m = PlatformCheckEnabled
+	case "false":
+		*m = PlatformCheckDisabled
+	case `"php-only"`:
+		*m = PlatformCheckPHPOnly
+	default:
+		return fmt.Errorf("config.platform-check must be true, false or \"php-only\"")
+	}
+	return nil
+}
+
+// enabled reports whether a platform check file should be generated; an
+// unset mode means Composer's default of php-only
+func (m PlatformCheckMode) enabled() bool {
+	return m != PlatformCheckDisabled
+}
+
+// versionBound is a lower bound on a version, e.g. ">= 8.2.0" or "> 7.4.0"
+type versionBound struct {
+	parts     [3]int
+	exclusive bool
+}
+
+func (b versionBound) compare(o versionBound) int {
+	for i := range b.parts {
+		if b.parts[i] != o.parts[i] {
+			if b.parts[i] < o.parts[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case b.exclusive == o.exclusive:
+		return 0
+	case b.exclusive:
+		return 1
+	default:
+		return -1
+	}
+}
+
+func (b versionBound) String() string {
+	op := ">="
+	if b.exclusive {
+		op = ">"
+	}
+	return fmt.Sprintf("%s %d.%d.%d", op, b.parts[0], b.parts[1], b.parts[2])
+}
+
+// phpVersionID returns the bound as a PHP_VERSION_ID (80200 for 8.2.0)
+func (b versionBound) phpVersionID() int {
+	return b.parts[0]*10000 + b.parts[1]*100 + b.parts[2]
+}
+
+// constraintLowerBound returns the smallest version a Composer constraint
+// can match, or false if the constraint has no lower bound (e.g. "<9", "*")
+func constraintLowerBound(constraint string) (versionBound, bool) {
+	set, err := parseConstraint(constraint)
+	if err != nil {
+		return versionBound{}, false
+	}
+
+	var lowest versionBound
+	found := false
+	for _, group := range set {
+		bound, ok := groupLowerBound(group)
+		if !ok {
+			return versionBound{}, false // One unbounded branch makes the whole constraint unbounded
+		}
+		if !found || bound.compare(lowest) < 0 {
+			lowest = bound
+			found = true
+		}
+	}
+	return lowest, found
+}
+
+// groupLowerBound returns the lower bound of an AND-combined constraint group
+func groupLowerBound(group []versionConstraint) (versionBound, bool) {
+	var highest versionBound
+	found := false
+	for _, c := range group {
+		if c.version.isBranch() {
+			continue
+		}
+		switch c.op {
+		case ">=", ">", "==":
+		default:
+			continue
+		}
+
+		var bound versionBound
+		copy(bound.parts[:], c.version.parts)
+		bound.exclusive = c.op == ">"
+		if !found || bound.compare(highest) > 0 {
+			highest = bound
+			found = true
+		}
+	}
+	return highest, found
+}
+
+// platformCheckRequirements collects what the generated check enforces: the
+// highest PHP lower bound, whether 64-bit PHP is needed and the required
+// extensions across the root package and all installed packages
+func platformCheckRequirements(packages []Package, rootRequire map[string]string, opts PlatformOptions) (versionBound, bool, bool, []string) {
+	var phpBound versionBound
+	hasPHPBound := false
+	needs64Bit := false
+	extensions := make(map[string]bool)
+
+	consider := func(require map[string]string) {
+		for name, constraint := range require {
+			name = strings.ToLower(name)
+			if !isPlatformRequirement(name) || opts.ignores(name) {
+				continue
+			}
+
+			switch {
+			case name == "php":
+				if bound, ok := constraintLowerBound(constraint); ok {
+					if !hasPHPBound || bound.compare(phpBound) > 0 {
+						phpBound = bound
+						hasPHPBound = true
+					}
+				}
+			case name == "php-64bit":
+				needs64Bit = true
+			case strings.HasPrefix(name, "ext-"):
+				extensions[strings.TrimPrefix(name, "ext-")] = true
+			}
+		}
+	}
+
+	consider(rootRequire)
+	for _, pkg := range packages {
+		consider(pkg.Require)
+	}
+
+	extList := make([]string, 0, len(extensions))
+	for ext := range extensions {
+		extList = append(extList, ext)
+	}
+	sort.Strings(extList)
+
+	return phpBound, hasPHPBound, needs64Bit, extList
+}
+
+// GeneratePlatformCheck writes vendor/composer/platform_check.php, which makes
+// the autoloader fail fast with a readable message when the runtime PHP does
+// not meet the installed packages' requirements. With platform-check disabled
+// any existing file is removed. Reports whether a check file was written.
+func GeneratePlatformCheck(ctx context.Context, packages []Package, rootRequire map[string]string, mode PlatformCheckMode, opts PlatformOptions, vendorDir string, logger *log.Logger) (bool, error) {
+	checkPath := filepath.Join(vendorDir, "composer", platformCheckFileName)
+	if mode == "" {
+		mode = PlatformCheckPHPOnly
+	}
+
+	if !mode.enabled() {
+		if err := os.Remove(checkPath); err != nil && !os.IsNotExist(err) {
+			return false, fmt.Errorf("remove %s: %w", platformCheckFileName, err)
+		}
+		logger.Debug("Platform check disabled by config.platform-check")
+		return false, nil
+	}
+
+	phpBound, hasPHPBound, needs64Bit, extensions := platformCheckRequirements(packages, rootRequire, opts)
+	if mode == PlatformCheckPHPOnly {
+		extensions = nil
+	}
+
+	var php strings.Builder
+	php.WriteString(`<?php
+
+// platform_check.php @generated by phpResolver
+
+$issues = array();
+
+`)
+
+	if hasPHPBound {
+		fmt.Fprintf(&php, `if (!(PHP_VERSION_ID %s %d)) {
+    $issues[] = 'Your Composer dependencies require a PHP version "%s". You are running ' . PHP_VERSION . '.';
+}
+
+`, strings.Fields(phpBound.String())[0], phpBound.phpVersionID(), phpBound)
+	}
+
+	if needs64Bit {
+		php.WriteString(`if (PHP_INT_SIZE !== 8) {
+    $issues[] = 'Your Composer dependencies require a 64-bit build of PHP.';
+}
+
+`)
+	}
+
+	if len(extensions) > 0 {
+		php.WriteString("$missingExtensions = array();\n")
+		for _, ext := range extensions {
+			loadedName := ext
+			if mapped, ok := zendExtensionMap[ext]; ok {
+				loadedName = mapped
+			}
+			fmt.Fprintf(&php, "extension_loaded(%s) || $missingExtensions[] = %s;\n", phpQuote(loadedName), phpQuote(ext))
+		}
+		php.WriteString(`
+if ($missingExtensions) {
+    $issues[] = 'Your Composer dependencies require the following PHP extensions to be installed: ' . implode(', ', $missingExtensions) . '.';
+}
+
+`)
+	}
+
+	php.WriteString(`if ($issues) {
+    if (!headers_sent()) {
+        header('HTTP/1.1 500 Internal Server Error');
+    }
+    if (!ini_get('display_errors')) {
+        if (PHP_SAPI === 'cli' || PHP_SAPI === 'phpdbg') {
+            fwrite(STDERR, 'Composer detected issues in your platform:' . PHP_EOL . PHP_EOL . implode(PHP_EOL, $issues) . PHP_EOL . PHP_EOL);
+        } elseif (!headers_sent()) {
+            echo 'Composer detected issues in your platform:' . PHP_EOL . PHP_EOL . str_replace('You are running ' . PHP_VERSION . '.', '', implode(PHP_EOL, $issues)) . PHP_EOL . PHP_EOL;
+        }
+    }
+    throw new \RuntimeException(
+        'Composer detected issues in your platform: ' . implode(' ', $issues)
+    );
+}
+`)
+
+	// Check for cancellation before file I/O
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	default:
+	}
+
+	if err := os.MkdirAll(filepath.Dir(checkPath), 0o755); err != nil {
+		return false, fmt.Errorf("create vendor/composer dir: %w", err)
+	}
+	if err := os.WriteFile(checkPath, []byte(php.String()), 0o644); err != nil {
+		return false, fmt.Errorf("write %s: %w", platformCheckFileName, err)
+	}
+
+	phpRequirement := "none"
+	if hasPHPBound {
+		phpRequirement = phpBound.String()
+	}
+	logger.Info("Generated platform check", "path", checkPath, "php", phpRequirement, "extensions", len(extensions), "mode", mode)
+	return true, nil
+}
+
+// phpQuote renders s as a single-quoted PHP string literal
+func phpQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
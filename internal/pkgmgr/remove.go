@@ -0,0 +1,153 @@
+package pkgmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/julian-richter/PhpResolver/internal/config"
+)
+
+// RemoveOptions holds the command-line options of remove
+type RemoveOptions struct {
+	InstallOptions
+	Packages []string // vendor/name
+	Dev      bool     // --dev: remove from require-dev instead of require
+}
+
+// RunRemove removes packages from composer.json, re-resolves and deletes
+// packages that are no longer required from vendor/, running their
+// pre-package-uninstall scripts. composer.json is restored if resolution or
+// installation fails; composer.lock is only written on success.
+func RunRemove(ctx context.Context, logger *log.Logger, cfg config.Config, opts RemoveOptions) (err error) {
+	if len(opts.Packages) == 0 {
+		return fmt.Errorf("no packages specified")
+	}
+
+	composerPath, err := FindComposerJSON(".")
+	if err != nil {
+		return fmt.Errorf("find composer.json: %w", err)
+	}
+	logger.Info("Found composer.json", "path", composerPath)
+
+	vendorDir := filepath.Join(filepath.Dir(composerPath), "vendor")
+	if err := os.MkdirAll(vendorDir, 0o755); err != nil {
+		return fmt.Errorf("create vendor dir: %w", err)
+	}
+
+	// Serialize runs that modify the same vendor/ tree. composer.json is
+	// only read under the lock, so a concurrent run's edit is never lost or
+	// overwritten by the restore below.
+	projectLock, err := acquireProjectLock(ctx, vendorDir, cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer projectLock.Release()
+
+	original, err := os.ReadFile(composerPath)
+	if err != nil {
+		return fmt.Errorf("read composer.json: %w", err)
+	}
+	info, err := os.Stat(composerPath)
+	if err != nil {
+		return fmt.Errorf("stat composer.json: %w", err)
+	}
+	composer, err := ParseComposerJSON(composerPath)
+	if err != nil {
+		return fmt.Errorf("parse composer.json: %w", err)
+	}
+
+	section, other := "require", "require-dev"
+	if opts.Dev {
+		section, other = other, section
+	}
+
+	// Like Composer, a package found only in the other section is removed
+	// from there with a warning
+	edited := original
+	var removedNames []string
+	for _, name := range opts.Packages {
+		name = strings.ToLower(strings.TrimSpace(name))
+		var removed bool
+		edited, removed, err = removeComposerLink(edited, section, name)
+		if err != nil {
+			return fmt.Errorf("edit composer.json: %w", err)
+		}
+		if !removed {
+			edited, removed, err = removeComposerLink(edited, other, name)
+			if err != nil {
+				return fmt.Errorf("edit composer.json: %w", err)
+			}
+			if removed {
+				logger.Warn("Package was not in "+section+", removed it from "+other, "package", name)
+			}
+		}
+		if !removed {
+			logger.Warn("Package is not required in composer.json", "package", name)
+			continue
+		}
+		removedNames = append(removedNames, name)
+	}
+	if len(removedNames) == 0 {
+		return fmt.Errorf("none of the packages are required in composer.json: %s", strings.Join(opts.Packages, ", "))
+	}
+
+	// Shared HTTP client with per-host credentials, proxy and TLS settings
+	client, err := newProjectHTTPClient(filepath.Dir(composerPath), composer, cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	if err := writeComposerJSON(composerPath, edited, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("write composer.json: %w", err)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		logger.Warn("Restoring composer.json", "path", composerPath)
+		if restoreErr := writeComposerJSON(composerPath, original, info.Mode().Perm()); restoreErr != nil {
+			err = errors.Join(err, fmt.Errorf("restore composer.json: %w", restoreErr))
+		}
+	}()
+
+	if err := updateProject(ctx, client, composerPath, vendorDir, removedNames, logger, cfg, opts.InstallOptions); err != nil {
+		return err
+	}
+
+	logger.Info("Remove complete", "packages", len(removedNames), "vendor_dir", vendorDir)
+	return nil
+}
+
+// uninstallPackages moves packages out of vendor/ into the stage's backup,
+// firing the package uninstall scripts around each removal
+func uninstallPackages(ctx context.Context, packages []Package, stage *vendorStage, scripts *scriptRunner, logger *log.Logger) error {
+	for _, pkg := range packages {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		// installed.json is data on disk; never let a name point outside vendor/
+		if !packageNameRE.MatchString(pkg.Name) {
+			logger.Warn("Not removing package with an invalid name", "package", pkg.Name)
+			continue
+		}
+
+		if err := scripts.runPackageEvent(ctx, scriptPrePackageUninstall, pkg); err != nil {
+			return fmt.Errorf("uninstall %s: %w", pkg.Name, err)
+		}
+		if err := stage.remove(pkg.Name); err != nil {
+			return err
+		}
+		if err := scripts.runPackageEvent(ctx, scriptPostPackageUninstall, pkg); err != nil {
+			return fmt.Errorf("uninstall %s: %w", pkg.Name, err)
+		}
+	}
+	return nil
+}
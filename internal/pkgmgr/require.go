@@ -0,0 +1,279 @@
+package pkgmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/julian-richter/PhpResolver/internal/config"
+)
+
+var (
+	packageNameRE    = regexp.MustCompile(`^[a-z0-9](?:[_.-]?[a-z0-9]+)*/[a-z0-9](?:(?:[_.]|-{1,2})?[a-z0-9]+)*$`)
+	semverPatchRE    = regexp.MustCompile(`^\d+\D?`)
+	branchAliasNines = regexp.MustCompile(`(?:\.9999999)+`)
+)
+
+// RequireOptions holds the command-line options of require
+type RequireOptions struct {
+	InstallOptions
+	Packages []string // vendor/name, optionally followed by :constraint or =constraint
+	Dev      bool     // --dev: add to require-dev instead of require
+}
+
+// RunRequire adds packages to composer.json and installs them. Packages
+// without a constraint get one derived from their newest stable version
+// (^x.y). composer.json is edited and restored if resolution or
+// installation fails; composer.lock is only written on success.
+func RunRequire(ctx context.Context, logger *log.Logger, cfg config.Config, opts RequireOptions) (err error) {
+	if len(opts.Packages) == 0 {
+		return fmt.Errorf("no packages specified")
+	}
+
+	composerPath, err := FindComposerJSON(".")
+	if err != nil {
+		return fmt.Errorf("find composer.json: %w", err)
+	}
+	logger.Info("Found composer.json", "path", composerPath)
+
+	links := make([][2]string, 0, len(opts.Packages))
+	for _, arg := range opts.Packages {
+		name, constraint, err := parseRequireArgument(arg)
+		if err != nil {
+			return err
+		}
+		links = append(links, [2]string{name, constraint})
+	}
+
+	vendorDir := filepath.Join(filepath.Dir(composerPath), "vendor")
+	if err := os.MkdirAll(vendorDir, 0o755); err != nil {
+		return fmt.Errorf("create vendor dir: %w", err)
+	}
+
+	// Serialize runs that modify the same vendor/ tree. composer.json is
+	// only read under the lock, so a concurrent run's edit is never lost or
+	// overwritten by the restore below.
+	projectLock, err := acquireProjectLock(ctx, vendorDir, cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer projectLock.Release()
+
+	original, err := os.ReadFile(composerPath)
+	if err != nil {
+		return fmt.Errorf("read composer.json: %w", err)
+	}
+	info, err := os.Stat(composerPath)
+	if err != nil {
+		return fmt.Errorf("stat composer.json: %w", err)
+	}
+	composer, err := ParseComposerJSON(composerPath)
+	if err != nil {
+		return fmt.Errorf("parse composer.json: %w", err)
+	}
+
+	// Shared HTTP client with per-host credentials, proxy and TLS settings
+	client, err := newProjectHTTPClient(filepath.Dir(composerPath), composer, cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	ropts := resolveOptions(composer, cfg, opts.InstallOptions)
+	for i, link := range links {
+		if link[1] != "" {
+			continue
+		}
+		latest, err := LatestVersion(ctx, client, link[0], ropts, logger)
+		if err != nil {
+			return fmt.Errorf("find a version of %s to require: %w", link[0], err)
+		}
+		links[i][1] = recommendedConstraint(latest)
+		logger.Info("Using version constraint", "package", link[0], "constraint", links[i][1])
+	}
+
+	section, other := "require", "require-dev"
+	if opts.Dev {
+		section, other = other, section
+	}
+	edited := original
+	for _, link := range links {
+		edited, err = setComposerLink(edited, section, link[0], link[1], composer.Config.SortPackages)
+		if err != nil {
+			return fmt.Errorf("edit composer.json: %w", err)
+		}
+		var removed bool
+		edited, removed, err = removeComposerLink(edited, other, link[0])
+		if err != nil {
+			return fmt.Errorf("edit composer.json: %w", err)
+		}
+		if removed {
+			logger.Info("Moved requirement", "package", link[0], "from", other, "to", section)
+		}
+	}
+
+	if err := writeComposerJSON(composerPath, edited, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("write composer.json: %w", err)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		logger.Warn("Restoring composer.json", "path", composerPath)
+		if restoreErr := writeComposerJSON(composerPath, original, info.Mode().Perm()); restoreErr != nil {
+			err = errors.Join(err, fmt.Errorf("restore composer.json: %w", restoreErr))
+		}
+	}()
+
+	required := make([]string, 0, len(links))
+	for _, link := range links {
+		required = append(required, link[0])
+	}
+	if err := updateProject(ctx, client, composerPath, vendorDir, required, logger, cfg, opts.InstallOptions); err != nil {
+		return err
+	}
+
+	logger.Info("Require complete", "packages", len(links), "vendor_dir", vendorDir)
+	return nil
+}
+
+// updateProject resolves the edited composer.json, keeping the locked
+// versions of every package except those in unlocked where they still fit,
+// installs the result and rewrites composer.lock
+func updateProject(ctx context.Context, client *HTTPClient, composerPath, vendorDir string, unlocked []string, logger *log.Logger, cfg config.Config, opts InstallOptions) error {
+	composer, err := ParseComposerJSON(composerPath)
+	if err != nil {
+		return fmt.Errorf("parse composer.json: %w", err)
+	}
+
+	ropts := resolveOptions(composer, cfg, opts)
+	// The lock predates the edit, so its content-hash is expected to be stale
+	lock, err := readLockFile(lockFilePath(composerPath))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		ropts.Preferred = make(map[string]string)
+		for _, pkg := range lock.allPackages() {
+			ropts.Preferred[pkg.Name] = pkg.Version
+		}
+		for _, name := range unlocked {
+			delete(ropts.Preferred, name)
+		}
+	}
+
+	packages, err := ResolvePackages(ctx, client, rootRequirements(composer), ropts, logger)
+	if err != nil {
+		return fmt.Errorf("resolve packages: %w", err)
+	}
+
+	if err := installPackages(ctx, client, composer, packages, devPackageNames(composer, packages), opts, vendorDir, logger, cfg); err != nil {
+		return err
+	}
+
+	return writeProjectLock(composerPath, composer, packages, logger)
+}
+
+// writeComposerJSON replaces composer.json through a temp file in the same
+// directory, so an interrupted write never leaves it truncated
+func writeComposerJSON(path string, data []byte, perm os.FileMode) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	tempPath := tempFile.Name()
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err := tempFile.Sync(); err != nil {
+		tempFile.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	if err := os.Chmod(tempPath, perm); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+// parseRequireArgument splits "vendor/name:^1.2" (or "=", or a space) into a
+// lowercase package name and a constraint, which is empty when none is given
+func parseRequireArgument(arg string) (string, string, error) {
+	name, constraint := strings.TrimSpace(arg), ""
+	if i := strings.IndexAny(name, ":= "); i >= 0 {
+		name, constraint = name[:i], strings.TrimSpace(name[i+1:])
+		if constraint == "" {
+			return "", "", fmt.Errorf("empty version constraint in %q", arg)
+		}
+	}
+
+	name = strings.ToLower(name)
+	if !packageNameRE.MatchString(name) && !isPlatformRequirement(name) {
+		return "", "", fmt.Errorf("invalid package name %q, expected vendor/name", name)
+	}
+	if isPlatformRequirement(name) && constraint == "" {
+		return "", "", fmt.Errorf("platform requirement %s needs an explicit version constraint", name)
+	}
+	if constraint != "" {
+		if _, err := parseConstraint(constraint); err != nil {
+			return "", "", fmt.Errorf("invalid version constraint for %s: %w", name, err)
+		}
+	}
+	return name, constraint, nil
+}
+
+// recommendedConstraint derives the constraint Composer would write for a
+// newly required package: ^major.minor (^0.minor.patch below 1.0), with a
+// stability flag for unstable versions. Dev branches use their branch alias
+// when they have one and are otherwise required as-is.
+func recommendedConstraint(pkg Package) string {
+	version := normalizeVersion(pkg.Version)
+	stability := versionStability(pkg.Version)
+
+	if strings.HasPrefix(version, "dev-") {
+		alias, ok := branchAlias(pkg.Version, pkg.Extra)
+		if !ok {
+			return pkg.Version
+		}
+		version = normalizeVersion(alias)
+	}
+	if strings.HasSuffix(version, "-dev") {
+		// Numbered branch: 1.5.9999999.9999999-dev becomes 1.5.0.0
+		version = branchAliasNines.ReplaceAllString(strings.TrimSuffix(version, "-dev"), ".0")
+		if n := len(strings.Split(version, ".")); n < 4 {
+			version += strings.Repeat(".0", 4-n)
+		}
+	}
+
+	parts := strings.Split(version, ".")
+	if len(parts) != 4 || !semverPatchRE.MatchString(parts[3]) {
+		return pkg.Version
+	}
+	if parts[0] == "0" {
+		parts = parts[:3]
+	} else {
+		parts = parts[:2]
+	}
+
+	constraint := "^" + strings.Join(parts, ".")
+	if stability != StabilityStable {
+		constraint += "@" + stability.String()
+	}
+	return constraint
+}
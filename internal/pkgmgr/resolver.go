@@ -3,62 +3,524 @@ package pkgmgr
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
 	"sort"
 	"strings"
-	"time"
+	"sync"
 
-	"github.com/Masterminds/semver/v3"
 	"github.com/charmbracelet/log"
 )
 
 var (
-	phpExtRE     = regexp.MustCompile(`^ext-(\w+)$`)
 	npmAssetRE   = regexp.MustCompile(`^npm-asset/`)
 	bowerAssetRE = regexp.MustCompile(`^bower-asset/`)
+
+	errNotInRepo = errors.New("package not found")
 )
 
-func ResolvePackages(ctx context.Context, require map[string]string, logger *log.Logger) ([]Package, error) {
-	return ResolvePackagesWithRepos(ctx, require, nil, logger)
+// rootRequirerName identifies the root package in requirement descriptions
+const rootRequirerName = "composer.json"
+
+// maxResolveIterations bounds the fixpoint loop in resolve. Each iteration
+// re-selects every package against the constraints of the previous selection;
+// real dependency graphs settle within a handful of iterations.
+const maxResolveIterations = 50
+
+// ResolveOptions configures dependency resolution
+type ResolveOptions struct {
+	Repositories []Repository
+	Platform     PlatformOptions
+	Concurrency  int // parallel repository metadata requests, defaults to 1
+
+	MinimumStability Stability // composer.json minimum-stability, defaults to stable
+	PreferStable     bool      // composer.json prefer-stable
+
+	// Links of the root package: names it replaces or provides are never
+	// installed and versions it conflicts with are never selected
+	Replace  map[string]string
+	Provide  map[string]string
+	Conflict map[string]string
+
+	// Preferred maps package names to versions to keep when they still
+	// satisfy every requirement, e.g. the locked versions during a partial update
+	Preferred map[string]string
 }
 
-func ResolvePackagesWithRepos(ctx context.Context, require map[string]string, repositories []Repository, logger *log.Logger) ([]Package, error) {
-	var packages []Package
-	var errors []string
+// requirement is a constraint on a package together with who imposed it
+type requirement struct {
+	Constraint string
+	RequiredBy string
+}
 
-	for name, constraint := range require {
-		// Skip PHP/platform requirements for MVP
-		if isPlatformRequirement(name) {
-			logger.Debug("Skipping platform requirement", "package", name)
-			continue
+// resolver selects package versions for a set of root requirements and
+// everything they transitively require
+type resolver struct {
+	client *HTTPClient
+	opts   ResolveOptions
+	logger *log.Logger
+
+	mu       sync.Mutex
+	metadata map[string][]Package // versions newest first, per package name
+	fetchErr map[string]error
+
+	platform       *platformView
+	stabilityFlags map[string]Stability   // per-package overrides from root @-flags
+	inlineAliases  map[string]inlineAlias // root "dev-fix as 2.3.0" requirements
+	root           Package                // the root package's replace/provide/conflict links
+}
+
+func ResolvePackages(ctx context.Context, client *HTTPClient, require map[string]string, opts ResolveOptions, logger *log.Logger) ([]Package, error) {
+	return newResolver(client, require, opts, logger).resolve(ctx, require)
+}
+
+// LatestVersion returns the newest version of name that is stable enough and
+// installable on the platform, as used to pick a constraint for new requirements
+func LatestVersion(ctx context.Context, client *HTTPClient, name string, opts ResolveOptions, logger *log.Logger) (Package, error) {
+	r := newResolver(client, nil, opts, logger)
+	return r.selectVersion(ctx, name, []requirement{{Constraint: "*", RequiredBy: rootRequirerName}}, nil)
+}
+
+func newResolver(client *HTTPClient, require map[string]string, opts ResolveOptions, logger *log.Logger) *resolver {
+	return &resolver{
+		client:   client,
+		opts:     opts,
+		logger:   logger,
+		metadata: make(map[string][]Package),
+		fetchErr: make(map[string]error),
+		platform: newPlatformView(opts.Platform, logger),
+
+		stabilityFlags: rootStabilityFlags(require),
+		inlineAliases:  rootInlineAliases(require),
+		root: Package{
+			Name:     rootRequirerName,
+			Replace:  opts.Replace,
+			Provide:  opts.Provide,
+			Conflict: opts.Conflict,
+		},
+	}
+}
+
+// resolve repeatedly selects the newest acceptable version of every required
+// package until the selection no longer changes. Requirements are recomputed
+// from scratch each round, so packages only required by a previously selected
+// version drop out again, as do packages that a selected package replaces or
+// provides. Resolution is greedy: there is no backtracking into older
+// versions of already selected packages to escape a conflict.
+func (r *resolver) resolve(ctx context.Context, require map[string]string) ([]Package, error) {
+	selected := make(map[string]Package)
+	var failures []string
+
+	for iteration := 1; ; iteration++ {
+		if iteration > maxResolveIterations {
+			return nil, fmt.Errorf("dependency resolution did not settle after %d iterations", maxResolveIterations)
 		}
 
-		pkg, err := resolvePackage(ctx, name, constraint, repositories, logger)
-		if err != nil {
-			logger.Warn("Failed to resolve package (skipping)", "package", name, "error", err.Error())
-			errors = append(errors, fmt.Sprintf("%s: %v", name, err))
-			continue // Skip this package but continue with others
+		reqs := collectRequirements(require, selected)
+		providers := collectProviders(r.root, selected)
+		failures = nil
+
+		var names []string
+		for name := range reqs {
+			if isPlatformRequirement(name) {
+				if err := r.checkPlatformRequirements(ctx, name, reqs[name]); err != nil {
+					var unmet platformError
+					if !errors.As(err, &unmet) {
+						return nil, err // Platform detection itself failed
+					}
+					failures = append(failures, err.Error())
+				}
+				continue
+			}
+			if target, ok := providerFor(providers, name, reqs[name]); ok {
+				r.logger.Debug("Requirement satisfied by another package", "package", name, "by", target.By, "replace", target.Replace)
+				continue
+			}
+			if r.rootReplaces(name) {
+				failures = append(failures, fmt.Sprintf("%s: replaced by %s in a version that doesn't match %s", name, rootRequirerName, describeRequirements(reqs[name])))
+				continue
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		if err := r.prefetch(ctx, names); err != nil {
+			return nil, err
 		}
+
+		// Conflicts are checked against this round's choices so far and the
+		// previous round's for the rest, so two conflicting packages don't
+		// keep swapping versions between rounds
+		current := make(map[string]Package, len(names))
+		for _, name := range names {
+			if pkg, ok := selected[name]; ok {
+				current[name] = pkg
+			}
+		}
+
+		next := make(map[string]Package, len(names))
+		for _, name := range names {
+			pkg, err := r.selectVersion(ctx, name, reqs[name], current)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				r.logger.Debug("Failed to resolve package", "package", name, "error", err.Error())
+				failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+				delete(current, name)
+				continue
+			}
+			next[name] = pkg
+			current[name] = pkg
+		}
+
+		settled := sameSelection(selected, next)
+		selected = next
+		if settled {
+			r.logger.Debug("Dependency resolution settled", "iterations", iteration, "packages", len(selected))
+			break
+		}
+	}
+
+	// Selection avoids conflicts with the previous round's packages; report
+	// any that remain between the final ones
+	for _, name := range sortedNames(selected) {
+		failures = append(failures, conflictsOf(selected[name], r.root, selected)...)
+	}
+
+	packages := make([]Package, 0, len(selected))
+	for _, pkg := range selected {
 		packages = append(packages, pkg)
 	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Name < packages[j].Name
+	})
 
 	// Log summary
-	if len(errors) > 0 {
-		logger.Warn("Some packages could not be resolved", "count", len(errors), "total", len(require))
+	if len(failures) > 0 {
+		r.logger.Warn("Some requirements could not be resolved", "count", len(failures))
 	}
-	logger.Info("Package resolution complete", "resolved", len(packages), "failed", len(errors))
+	r.logger.Info("Package resolution complete", "resolved", len(packages), "failed", len(failures))
 
-	// Return error if any packages failed to resolve
-	if len(errors) > 0 {
-		return packages, fmt.Errorf("failed to resolve %d package(s): %s", len(errors), strings.Join(errors, "; "))
+	// Return error if any requirement failed to resolve
+	if len(failures) > 0 {
+		return packages, fmt.Errorf("failed to resolve %d requirement(s): %s", len(failures), strings.Join(failures, "; "))
 	}
 
 	return packages, nil
 }
 
-func resolvePackage(ctx context.Context, name, constraint string, repositories []Repository, logger *log.Logger) (Package, error) {
+// collectRequirements gathers the constraints imposed by the root package and
+// every selected package, keyed by lowercase package name
+func collectRequirements(root map[string]string, selected map[string]Package) map[string][]requirement {
+	reqs := make(map[string][]requirement)
+	add := func(name, constraint, requiredBy, selfVersion string) {
+		if strings.TrimSpace(constraint) == "self.version" {
+			constraint = selfVersion
+		}
+		name = strings.ToLower(name)
+		reqs[name] = append(reqs[name], requirement{Constraint: constraint, RequiredBy: requiredBy})
+	}
+
+	for name, constraint := range root {
+		add(name, constraint, rootRequirerName, "")
+	}
+	for _, pkg := range selected {
+		for name, constraint := range pkg.Require {
+			add(name, constraint, pkg.Name, pkg.Version)
+		}
+	}
+	return reqs
+}
+
+// rootReplaces reports whether the root package replaces name, which then
+// can never be installed
+func (r *resolver) rootReplaces(name string) bool {
+	for replaced := range r.root.Replace {
+		if strings.EqualFold(replaced, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedNames(selected map[string]Package) []string {
+	names := make([]string, 0, len(selected))
+	for name := range selected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sameSelection(a, b map[string]Package) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, pkg := range a {
+		other, ok := b[name]
+		if !ok || other.Version != pkg.Version {
+			return false
+		}
+	}
+	return true
+}
+
+// installable reports whether a version can be installed: from a dist the
+// HTTP policy allows, or by cloning its git source
+func (r *resolver) installable(pkg Package) bool {
+	if pkg.Dist.URL != "" && r.client.AllowsURL(pkg.Dist.URL) {
+		return true
+	}
+	return hasGitSource(pkg)
+}
+
+// selectVersion picks the newest version of name that satisfies every
+// requirement, is stable enough, has a usable dist or git source, doesn't
+// conflict with the root or the other selected packages and whose own
+// platform requirements are met. With prefer-stable the most stable such
+// version wins; a preferred version wins over both.
+func (r *resolver) selectVersion(ctx context.Context, name string, reqs []requirement, selected map[string]Package) (Package, error) {
+	versions, err := r.packageVersions(ctx, name)
+	if err != nil {
+		return Package{}, err
+	}
+
+	allowed := r.allowedStability(name)
+	if r.opts.PreferStable {
+		versions = preferStable(versions)
+	}
+	if preferred, ok := r.opts.Preferred[name]; ok {
+		versions = preferVersion(versions, preferred)
+	}
+
+	var platformRejection error
+	var conflict string
+	unstableSkipped := false
+	for _, pkg := range versions {
+		if !r.installable(pkg) {
+			continue
+		}
+		if !satisfiesAll(pkg, reqs) {
+			continue
+		}
+		if !allowed.allows(versionStability(pkg.Version)) {
+			unstableSkipped = true
+			continue
+		}
+		if conflicts := conflictsOf(pkg, r.root, selected); len(conflicts) > 0 {
+			r.logger.Debug("Skipping conflicting version", "package", name, "version", pkg.Version, "reason", conflicts[0])
+			if conflict == "" {
+				conflict = conflicts[0]
+			}
+			continue
+		}
+		if err := r.checkPackagePlatform(ctx, pkg); err != nil {
+			if ctx.Err() != nil {
+				return Package{}, ctx.Err()
+			}
+			var unmet platformError
+			if !errors.As(err, &unmet) {
+				return Package{}, err
+			}
+			r.logger.Debug("Skipping version with unmet platform requirements", "package", name, "version", pkg.Version, "reason", err)
+			if platformRejection == nil {
+				platformRejection = fmt.Errorf("%s %s requires %w", pkg.Name, pkg.Version, err)
+			}
+			continue
+		}
+
+		r.logger.Debug("Resolved package", "package", name, "version", pkg.Version)
+		return pkg, nil
+	}
+
+	if platformRejection != nil {
+		return Package{}, fmt.Errorf("no installable version matches %s; %w", describeRequirements(reqs), platformRejection)
+	}
+	if conflict != "" {
+		return Package{}, fmt.Errorf("no version matching %s can be installed: %s", describeRequirements(reqs), conflict)
+	}
+	if unstableSkipped {
+		return Package{}, fmt.Errorf("no version matching %s is stable enough (allowed: %s; lower minimum-stability or add a flag such as @dev to the root requirement)", describeRequirements(reqs), allowed)
+	}
+	return Package{}, fmt.Errorf("no version with a usable dist matches %s", describeRequirements(reqs))
+}
+
+// allowedStability returns the least stable level acceptable for name: the
+// minimum-stability, lowered further by a root @-flag or unstable root constraint
+func (r *resolver) allowedStability(name string) Stability {
+	allowed := r.opts.MinimumStability
+	if flag, ok := r.stabilityFlags[name]; ok && !allowed.allows(flag) {
+		allowed = flag
+	}
+	return allowed
+}
+
+// preferStable reorders versions (newest first) so that more stable versions
+// come before less stable ones, keeping newest-first order within each level
+func preferStable(versions []Package) []Package {
+	ordered := make([]Package, len(versions))
+	copy(ordered, versions)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return versionStability(ordered[i].Version).rank() < versionStability(ordered[j].Version).rank()
+	})
+	return ordered
+}
+
+// preferVersion moves the given version to the front of versions
+func preferVersion(versions []Package, version string) []Package {
+	target := normalizeVersion(version)
+	ordered := make([]Package, 0, len(versions))
+	for _, pkg := range versions {
+		if normalizeVersion(pkg.Version) == target {
+			ordered = append(ordered, pkg)
+		}
+	}
+	for _, pkg := range versions {
+		if normalizeVersion(pkg.Version) != target {
+			ordered = append(ordered, pkg)
+		}
+	}
+	return ordered
+}
+
+func satisfiesAll(pkg Package, reqs []requirement) bool {
+	for _, req := range reqs {
+		if !satisfiedBy(pkg, req.Constraint) {
+			return false
+		}
+	}
+	return true
+}
+
+func describeRequirements(reqs []requirement) string {
+	parts := make([]string, 0, len(reqs))
+	for _, req := range reqs {
+		parts = append(parts, fmt.Sprintf("%s (required by %s)", req.Constraint, req.RequiredBy))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// checkPlatformRequirements enforces every requirement on a platform package
+// (root and transitive) unless it is ignored
+func (r *resolver) checkPlatformRequirements(ctx context.Context, name string, reqs []requirement) error {
+	if r.opts.Platform.ignores(name) {
+		r.logger.Debug("Ignoring platform requirement", "requirement", name)
+		return nil
+	}
+
+	for _, req := range reqs {
+		if err := r.platform.check(ctx, name, req.Constraint); err != nil {
+			var unmet platformError
+			if !errors.As(err, &unmet) {
+				return err
+			}
+			return platformError{fmt.Errorf("%s requires %s %s: %w", req.RequiredBy, name, req.Constraint, err)}
+		}
+	}
+	return nil
+}
+
+// checkPackagePlatform checks a candidate version's own platform requirements
+func (r *resolver) checkPackagePlatform(ctx context.Context, pkg Package) error {
+	names := make([]string, 0, len(pkg.Require))
+	for name := range pkg.Require {
+		if isPlatformRequirement(name) && !r.opts.Platform.ignores(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := r.platform.check(ctx, name, pkg.Require[name]); err != nil {
+			var unmet platformError
+			if !errors.As(err, &unmet) {
+				return err
+			}
+			return platformError{fmt.Errorf("%s %s: %w", name, pkg.Require[name], err)}
+		}
+	}
+	return nil
+}
+
+// prefetch loads metadata for all names not yet known, in parallel
+func (r *resolver) prefetch(ctx context.Context, names []string) error {
+	concurrency := r.opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for _, name := range names {
+		r.mu.Lock()
+		_, known := r.metadata[name]
+		_, failed := r.fetchErr[name]
+		r.mu.Unlock()
+		if known || failed {
+			continue
+		}
+
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+
+			// Errors are recorded by packageVersions and reported on selection
+			_, _ = r.packageVersions(ctx, name)
+		}(name)
+	}
+
+	wg.Wait()
+	return ctx.Err()
+}
+
+// packageVersions returns all versions of name (newest first) from the first
+// repository that knows the package, caching the result
+func (r *resolver) packageVersions(ctx context.Context, name string) ([]Package, error) {
+	r.mu.Lock()
+	if versions, ok := r.metadata[name]; ok {
+		r.mu.Unlock()
+		return versions, nil
+	}
+	if err, ok := r.fetchErr[name]; ok {
+		r.mu.Unlock()
+		return nil, err
+	}
+	r.mu.Unlock()
+
+	versions, err := findPackageVersions(ctx, r.client, name, r.opts.Repositories, r.logger)
+	if err == nil {
+		if alias, ok := r.inlineAliases[name]; ok {
+			r.logger.Debug("Applying inline alias", "package", name, "version", alias.Version, "alias", alias.Alias)
+			versions = applyInlineAlias(versions, alias)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		if ctx.Err() == nil {
+			r.fetchErr[name] = err
+		}
+		return nil, err
+	}
+	r.metadata[name] = versions
+	return versions, nil
+}
+
+// findPackageVersions looks name up in the configured repositories, falling
+// back to Packagist. The first repository that has the package wins.
+func findPackageVersions(ctx context.Context, client *HTTPClient, name string, repositories []Repository, logger *log.Logger) ([]Package, error) {
 	// Check if this is an asset package (npm-asset/ or bower-asset/)
 	isAsset := isAssetPackage(name)
 
@@ -69,24 +531,24 @@ func resolvePackage(ctx context.Context, name, constraint string, repositories [
 		for _, repo := range repositories {
 			if repo.Type == "composer" && strings.Contains(repo.URL, "asset-packagist.org") {
 				logger.Debug("Trying asset-packagist", "package", name, "url", repo.URL)
-				pkg, err := queryComposerRepository(ctx, repo.URL, name, constraint, logger)
+				versions, err := queryComposerRepository(ctx, client, repo.URL, name, logger)
 				if err == nil {
-					return pkg, nil
+					return versions, nil
 				}
 				logger.Debug("Asset package not found in asset-packagist", "package", name, "error", err)
 			}
 		}
 		// If asset-packagist is not configured or package not found, return an error
-		return Package{}, fmt.Errorf("asset package %s not found in asset-packagist.org", name)
+		return nil, fmt.Errorf("asset package %s not found in asset-packagist.org", name)
 	}
 
 	// Try custom composer repositories first (skip asset-packagist as it was tried above for assets)
 	for _, repo := range repositories {
 		if repo.Type == "composer" && !strings.Contains(repo.URL, "asset-packagist.org") {
 			logger.Debug("Trying custom composer repository", "package", name, "repo", repo.URL)
-			pkg, err := queryComposerRepository(ctx, repo.URL, name, constraint, logger)
+			versions, err := queryComposerRepository(ctx, client, repo.URL, name, logger)
 			if err == nil {
-				return pkg, nil
+				return versions, nil
 			}
 			logger.Debug("Package not found in custom repository", "package", name, "repo", repo.URL, "error", err)
 		} else if repo.Type == "git" {
@@ -98,113 +560,72 @@ func resolvePackage(ctx context.Context, name, constraint string, repositories [
 
 	// Fallback to Packagist
 	logger.Debug("Trying packagist.org", "package", name)
-	return queryComposerRepository(ctx, "https://packagist.org", name, constraint, logger)
+	return queryComposerRepository(ctx, client, "https://packagist.org", name, logger)
 }
 
-func queryComposerRepository(ctx context.Context, baseURL, name, constraint string, logger *log.Logger) (Package, error) {
+// queryComposerRepository fetches every version of name from a Composer
+// repository, sorted newest first. Version entries that fail to decode are
+// skipped rather than failing the whole package.
+func queryComposerRepository(ctx context.Context, client *HTTPClient, baseURL, name string, logger *log.Logger) ([]Package, error) {
 	url := fmt.Sprintf("%s/packages/%s.json", baseURL, name)
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return Package{}, fmt.Errorf("create request for %s: %w", name, err)
+		return nil, fmt.Errorf("create request for %s: %w", name, err)
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return Package{}, fmt.Errorf("repository lookup %s: %w", name, err)
+		return nil, fmt.Errorf("repository lookup %s: %w", name, err)
 	}
 	defer resp.Body.Close()
 
+	if isAuthStatus(resp.StatusCode) {
+		return nil, authError(resp.Request.URL, resp.Status)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s in %s: %w", name, baseURL, errNotInRepo)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return Package{}, fmt.Errorf("repository %s returned %s for %s", baseURL, resp.Status, name)
+		return nil, fmt.Errorf("repository %s returned %s for %s", baseURL, resp.Status, name)
 	}
 
 	var data struct {
 		Package struct {
-			Versions map[string]struct {
-				Dist Dist `json:"dist"`
-			} `json:"versions"`
+			Versions map[string]json.RawMessage `json:"versions"`
 		} `json:"package"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return Package{}, fmt.Errorf("decode repository response for %s: %w", name, err)
-	}
-
-	// Collect and sort versions deterministically (latest first using version comparison)
-	var versions []string
-	for version := range data.Package.Versions {
-		versions = append(versions, version)
+		return nil, fmt.Errorf("decode repository response for %s: %w", name, err)
 	}
 
-	// Sort versions using version-aware comparison (reverse order for latest first)
-	sort.Slice(versions, func(i, j int) bool {
-		vi, vj := versions[i], versions[j]
-		return compareVersions(vi, vj) > 0 // Reverse order: higher versions first
-	})
-
-	// Iterate through sorted versions and pick the first one with HTTPS dist URL
-	for _, version := range versions {
-		vdata := data.Package.Versions[version]
-		if vdata.Dist.URL != "" && strings.HasPrefix(vdata.Dist.URL, "https://") {
-			logger.Debug("Resolved package", "package", name, "version", version, "repo", baseURL)
-			return Package{
-				Name:    name,
-				Version: version,
-				Dist:    vdata.Dist,
-			}, nil
+	versions := make([]Package, 0, len(data.Package.Versions))
+	for version, raw := range data.Package.Versions {
+		var meta packageMetadata
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			logger.Debug("Skipping undecodable version metadata", "package", name, "version", version, "error", err)
+			continue
 		}
+		pkg := meta.toPackage()
+		pkg.Name = name
+		pkg.Version = version
+		if alias, ok := branchAlias(version, pkg.Extra); ok {
+			pkg.Aliases = []string{alias}
+		}
+		versions = append(versions, pkg)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%s in %s has no versions: %w", name, baseURL, errNotInRepo)
 	}
 
-	return Package{}, fmt.Errorf("no HTTPS dist found for %s", name)
-}
+	// Newest first; branches with a branch alias rank by their alias
+	sortVersionsNewestFirst(versions)
 
-func isPlatformRequirement(name string) bool {
-	return phpExtRE.MatchString(name) || name == "php"
+	logger.Debug("Fetched package metadata", "package", name, "versions", len(versions), "repo", baseURL)
+	return versions, nil
 }
 
 func isAssetPackage(name string) bool {
 	return npmAssetRE.MatchString(name) || bowerAssetRE.MatchString(name)
 }
-
-// compareVersions compares two version strings using proper semver rules and returns:
-// -1 if v1 < v2
-//
-//	0 if v1 == v2
-//	1 if v1 > v2
-//
-// This function properly handles pre-release suffixes, build metadata, and follows
-// semantic versioning rules as defined in https://semver.org/
-func compareVersions(v1, v2 string) int {
-	// Parse versions using semver library
-	ver1, err1 := semver.NewVersion(v1)
-	ver2, err2 := semver.NewVersion(v2)
-
-	// If both versions parse successfully, compare them properly
-	if err1 == nil && err2 == nil {
-		return ver1.Compare(ver2)
-	}
-
-	// If one or both versions fail to parse, fall back to string comparison
-	// This handles edge cases like non-standard version strings
-	if err1 != nil && err2 != nil {
-		// Both failed to parse, compare as strings
-		if v1 < v2 {
-			return -1
-		} else if v1 > v2 {
-			return 1
-		}
-		return 0
-	}
-
-	// If only one failed to parse, consider the parsable one as greater
-	// This is a reasonable fallback for mixed version formats
-	if err1 != nil {
-		return -1 // v1 failed to parse, consider v2 greater
-	}
-	return 1 // v2 failed to parse, consider v1 greater
-}
@@ -0,0 +1,93 @@
+package pkgmgr
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// newTestRepository serves Composer package metadata (packages/<name>.json)
+// from metadata, keyed by package name. "SRV" in the metadata is replaced by
+// the server's URL.
+func newTestRepository(t *testing.T, metadata map[string]string) (*HTTPClient, ResolveOptions) {
+	t.Helper()
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/packages/"), ".json")
+		data, ok := metadata[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, strings.ReplaceAll(data, "SRV", srv.URL))
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := NewHTTPClient(HTTPOptions{Timeout: 5 * time.Second, ConnectTimeout: 5 * time.Second}, nil)
+	if err != nil {
+		t.Fatalf("NewHTTPClient: %v", err)
+	}
+	opts := ResolveOptions{
+		Repositories: []Repository{{Type: "composer", URL: srv.URL}},
+		Platform:     PlatformOptions{IgnoreAll: true},
+	}
+	return client, opts
+}
+
+func TestResolveReplacedPackageConflicts(t *testing.T) {
+	client, opts := newTestRepository(t, map[string]string{
+		"laravel/framework": `{"package": {"versions": {
+			"v10.1.0": {"dist": {"url": "SRV/framework.zip", "type": "zip"}, "replace": {"illuminate/support": "self.version"}}
+		}}}`,
+		"spatie/x": `{"package": {"versions": {
+			"1.0.0": {"dist": {"url": "SRV/x.zip", "type": "zip"}, "require": {"illuminate/support": "^11.0"}}
+		}}}`,
+		"illuminate/support": `{"package": {"versions": {
+			"v11.0.0": {"dist": {"url": "SRV/support.zip", "type": "zip"}}
+		}}}`,
+	})
+
+	require := map[string]string{"laravel/framework": "^10.0", "spatie/x": "^1.0"}
+	packages, err := ResolvePackages(context.Background(), client, require, opts, log.New(io.Discard))
+	if err == nil {
+		t.Fatalf("ResolvePackages succeeded with %v, want a conflict between laravel/framework and illuminate/support", packages)
+	}
+	if !strings.Contains(err.Error(), "replaces illuminate/support") {
+		t.Errorf("error %q does not mention the replaced package", err)
+	}
+}
+
+func TestResolveReplacedPackageSatisfiesRequirement(t *testing.T) {
+	client, opts := newTestRepository(t, map[string]string{
+		"laravel/framework": `{"package": {"versions": {
+			"v11.2.0": {"dist": {"url": "SRV/framework.zip", "type": "zip"}, "replace": {"illuminate/support": "self.version"}}
+		}}}`,
+		"spatie/x": `{"package": {"versions": {
+			"1.0.0": {"dist": {"url": "SRV/x.zip", "type": "zip"}, "require": {"illuminate/support": "^11.0"}}
+		}}}`,
+		"illuminate/support": `{"package": {"versions": {
+			"v11.0.0": {"dist": {"url": "SRV/support.zip", "type": "zip"}}
+		}}}`,
+	})
+
+	require := map[string]string{"laravel/framework": "^11.0", "spatie/x": "^1.0"}
+	packages, err := ResolvePackages(context.Background(), client, require, opts, log.New(io.Discard))
+	if err != nil {
+		t.Fatalf("ResolvePackages: %v", err)
+	}
+
+	var names []string
+	for _, pkg := range packages {
+		names = append(names, pkg.Name+" "+pkg.Version)
+	}
+	if got, want := strings.Join(names, ", "), "laravel/framework v11.2.0, spatie/x 1.0.0"; got != want {
+		t.Errorf("resolved %s, want %s", got, want)
+	}
+}
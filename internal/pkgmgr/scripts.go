@@ -0,0 +1,272 @@
+package pkgmgr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/julian-richter/PhpResolver/internal/config"
+)
+
+// Script events fired while packages are installed and removed
+const (
+	scriptPrePackageUninstall  = "pre-package-uninstall"
+	scriptPostPackageUninstall = "post-package-uninstall"
+)
+
+// defaultProcessTimeout is Composer's default config.process-timeout
+const defaultProcessTimeout = 300 * time.Second
+
+// phpCallbackRE matches scripts that name a static PHP method, such as
+// "Vendor\\Installer::postInstall"
+var phpCallbackRE = regexp.MustCompile(`^[A-Za-z_\\][A-Za-z0-9_\\]*::[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (s *Scripts) UnmarshalJSON(data []byte) error {
+	if isEmptyJSONArray(data) {
+		*s = nil
+		return nil
+	}
+	var m map[string]StringOrArray
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("scripts must be an object of commands: %w", err)
+	}
+	*s = m
+	return nil
+}
+
+// scriptRunner executes composer.json scripts from the project directory.
+// Shell commands, @php, @composer, @putenv, references to other scripts and
+// PHP callbacks are supported. Package events describe their package in
+// COMPOSER_PACKAGE_NAME and COMPOSER_PACKAGE_VERSION.
+type scriptRunner struct {
+	scripts    Scripts
+	projectDir string
+	vendorDir  string
+	phpBinary  string
+	timeout    time.Duration
+	env        []string
+	logger     *log.Logger
+}
+
+func newScriptRunner(composer ComposerJSON, vendorDir string, cfg config.Config, logger *log.Logger) *scriptRunner {
+	timeout := defaultProcessTimeout
+	if composer.Config.ProcessTimeout > 0 {
+		timeout = time.Duration(composer.Config.ProcessTimeout) * time.Second
+	}
+
+	vendorDir, _ = filepath.Abs(vendorDir)
+	env := append(os.Environ(),
+		"PATH="+filepath.Join(vendorDir, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"),
+		"COMPOSER_DEV_MODE=1",
+		"COMPOSER_VENDOR_DIR="+vendorDir,
+	)
+
+	return &scriptRunner{
+		scripts:    composer.Scripts,
+		projectDir: filepath.Dir(vendorDir),
+		vendorDir:  vendorDir,
+		phpBinary:  cfg.Pkgmgr.PHPBinary,
+		timeout:    timeout,
+		env:        env,
+		logger:     logger,
+	}
+}
+
+// runPackageEvent executes the commands registered for a package event,
+// stopping at the first one that fails
+func (r *scriptRunner) runPackageEvent(ctx context.Context, event string, pkg Package) error {
+	env := []string{"COMPOSER_PACKAGE_NAME=" + pkg.Name, "COMPOSER_PACKAGE_VERSION=" + pkg.Version}
+	return r.runScript(ctx, event, env, map[string]bool{})
+}
+
+func (r *scriptRunner) runScript(ctx context.Context, name string, env []string, active map[string]bool) error {
+	commands, ok := r.scripts[name]
+	if !ok || len(commands) == 0 {
+		return nil
+	}
+	if active[name] {
+		return fmt.Errorf("script %s references itself", name)
+	}
+	active[name] = true
+	defer delete(active, name)
+
+	for _, command := range commands {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		command = strings.TrimSpace(command)
+		switch {
+		case command == "":
+			continue
+		case phpCallbackRE.MatchString(command):
+			if err := r.callPHP(ctx, name, command, env); err != nil {
+				return err
+			}
+		case strings.HasPrefix(command, "@putenv "):
+			env = append(env, strings.TrimSpace(strings.TrimPrefix(command, "@putenv ")))
+		case strings.HasPrefix(command, "@php ") || command == "@php":
+			if err := r.exec(ctx, name, shellQuote(r.phpBinary)+strings.TrimPrefix(command, "@php"), env); err != nil {
+				return err
+			}
+		case strings.HasPrefix(command, "@composer ") || command == "@composer":
+			self, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("script %s: locate executable: %w", name, err)
+			}
+			if err := r.exec(ctx, name, shellQuote(self)+strings.TrimPrefix(command, "@composer"), env); err != nil {
+				return err
+			}
+		case strings.HasPrefix(command, "@"):
+			ref, _, _ := strings.Cut(command[1:], " ")
+			if _, ok := r.scripts[ref]; !ok {
+				return fmt.Errorf("script %s references unknown script %s", name, ref)
+			}
+			if err := r.runScript(ctx, ref, env, active); err != nil {
+				return err
+			}
+		default:
+			if err := r.exec(ctx, name, command, env); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *scriptRunner) exec(ctx context.Context, name, line string, env []string) error {
+	return r.runCommand(ctx, name, line, env, func(ctx context.Context) *exec.Cmd {
+		return shellCommand(ctx, line)
+	})
+}
+
+// callPHP calls a static method through phpCallbackBootstrap, with a
+// Composer-like event object for its argument
+func (r *scriptRunner) callPHP(ctx context.Context, name, callback string, env []string) error {
+	if _, err := os.Stat(filepath.Join(r.vendorDir, "autoload.php")); err != nil {
+		return fmt.Errorf("script %s: PHP callback %s needs vendor/autoload.php: %w", name, callback, err)
+	}
+	return r.runCommand(ctx, name, callback, env, func(ctx context.Context) *exec.Cmd {
+		return exec.CommandContext(ctx, r.phpBinary, "-r", phpCallbackBootstrap, "--", callback, name)
+	})
+}
+
+// runCommand runs the command newCmd builds, subject to the process timeout
+func (r *scriptRunner) runCommand(ctx context.Context, name, line string, env []string, newCmd func(context.Context) *exec.Cmd) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	r.logger.Info("Running script", "event", name, "command", line)
+	cmd := newCmd(ctx)
+	cmd.Dir = r.projectDir
+	cmd.Env = append(append([]string(nil), r.env...), env...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("script %s: %q exceeded the process timeout of %s", name, line, r.timeout)
+		}
+		return fmt.Errorf("script %s: %q failed: %w", name, line, err)
+	}
+	return nil
+}
+
+// shellQuote quotes s for use as a single shell word
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`&|;<>()*?[]{}!#~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// phpCallbackBootstrap runs a PHP callback script: it loads the project's
+// autoloader and calls the method with an event object that offers the
+// parts of Composer's Event and PackageEvent API that callbacks commonly
+// use. The callback and event name are passed as arguments, the package of
+// a package event in COMPOSER_PACKAGE_NAME and COMPOSER_PACKAGE_VERSION.
+// The stand-in classes are declared before the autoloader is loaded, so
+// callbacks type-hinting Composer's classes accept them.
+const phpCallbackBootstrap = `
+namespace Composer\Package {
+    class Package {
+        private $name; private $version;
+        public function __construct($name, $version) { $this->name = $name; $this->version = $version; }
+        public function getName() { return strtolower($this->name); }
+        public function getPrettyName() { return $this->name; }
+        public function getVersion() { return $this->version; }
+        public function getPrettyVersion() { return $this->version; }
+    }
+}
+namespace Composer\DependencyResolver\Operation {
+    class UninstallOperation {
+        private $package;
+        public function __construct($package) { $this->package = $package; }
+        public function getPackage() { return $this->package; }
+        public function getOperationType() { return 'uninstall'; }
+    }
+}
+namespace Composer\IO {
+    class ConsoleIO {
+        public function write($messages, $newline = true) { foreach ((array) $messages as $m) { echo $m, $newline ? PHP_EOL : ''; } }
+        public function writeError($messages, $newline = true) { foreach ((array) $messages as $m) { fwrite(STDERR, $m . ($newline ? PHP_EOL : '')); } }
+        public function isInteractive() { return false; }
+        public function isVerbose() { return false; }
+        public function isVeryVerbose() { return false; }
+        public function isDebug() { return false; }
+        public function isDecorated() { return false; }
+    }
+}
+namespace Composer\Config {
+    class Config {
+        public function get($key) {
+            $vendorDir = getenv('COMPOSER_VENDOR_DIR');
+            switch ($key) {
+                case 'vendor-dir': return $vendorDir;
+                case 'bin-dir': return $vendorDir . '/bin';
+                default: return null;
+            }
+        }
+    }
+}
+namespace Composer {
+    class Composer {
+        public function getConfig() { return new \Composer\Config\Config(); }
+    }
+}
+namespace Composer\Script {
+    class Event {
+        private $name;
+        public function __construct($name) { $this->name = $name; }
+        public function getName() { return $this->name; }
+        public function getComposer() { return new \Composer\Composer(); }
+        public function getIO() { return new \Composer\IO\ConsoleIO(); }
+        public function isDevMode() { return getenv('COMPOSER_DEV_MODE') === '1'; }
+        public function getArguments() { return array(); }
+        public function getFlags() { return array(); }
+    }
+}
+namespace Composer\Installer {
+    class PackageEvent extends \Composer\Script\Event {
+        public function getOperation() {
+            $package = new \Composer\Package\Package(getenv('COMPOSER_PACKAGE_NAME'), getenv('COMPOSER_PACKAGE_VERSION'));
+            return new \Composer\DependencyResolver\Operation\UninstallOperation($package);
+        }
+    }
+}
+namespace {
+    require getenv('COMPOSER_VENDOR_DIR') . '/autoload.php';
+    list(, $callback, $event) = $argv;
+    $class = getenv('COMPOSER_PACKAGE_NAME') !== false ? 'Composer\\Installer\\PackageEvent' : 'Composer\\Script\\Event';
+    call_user_func($callback, new $class($event));
+}
+`
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package pkgmgr
+
+import (
+	"context"
+	"os/exec"
+)
+
+// shellCommand runs a script line through cmd.exe
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	return exec.CommandContext(ctx, "cmd", "/C", line)
+}
@@ -0,0 +1,13 @@
+//go:build unix
+
+package pkgmgr
+
+import (
+	"context"
+	"os/exec"
+)
+
+// shellCommand runs a script line through the POSIX shell
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", line)
+}
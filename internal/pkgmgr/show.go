@@ -0,0 +1,364 @@
+package pkgmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/log"
+	"github.com/julian-richter/PhpResolver/internal/config"
+)
+
+// ShowOptions holds command-line options for show
+type ShowOptions struct {
+	Package   string // vendor/name to show in detail; empty lists all packages
+	All       bool   // --all: also list the versions available in the repositories
+	Installed bool   // --installed: read vendor/composer/installed.json (default)
+	Locked    bool   // --locked: read composer.lock
+	Tree      bool   // --tree: render the dependency tree
+	Self      bool   // --self: show the root package
+}
+
+// RunShow prints the installed (or locked) packages, one package in detail,
+// the dependency tree or the root package
+func RunShow(ctx context.Context, logger *log.Logger, cfg config.Config, opts ShowOptions) error {
+	sources := 0
+	for _, set := range []bool{opts.All, opts.Installed, opts.Locked} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return fmt.Errorf("--all, --installed and --locked cannot be combined")
+	}
+	if opts.All && opts.Package == "" {
+		return fmt.Errorf("--all needs a package name, repositories are queried one package at a time")
+	}
+
+	composerPath, err := FindComposerJSON(".")
+	if err != nil {
+		return fmt.Errorf("find composer.json: %w", err)
+	}
+	composer, err := ParseComposerJSON(composerPath)
+	if err != nil {
+		return fmt.Errorf("parse composer.json: %w", err)
+	}
+
+	if opts.Self {
+		return writeRootPackage(os.Stdout, composer)
+	}
+
+	packages, err := showPackages(composerPath, opts.Locked, opts.All, logger)
+	if err != nil {
+		return err
+	}
+	name := strings.ToLower(opts.Package)
+
+	if opts.Tree {
+		return writeDependencyTree(os.Stdout, composer, packages, name)
+	}
+	if name == "" {
+		return writePackageList(os.Stdout, packages)
+	}
+
+	var installed *Package
+	for i := range packages {
+		if packages[i].Name == name {
+			installed = &packages[i]
+			break
+		}
+	}
+
+	if !opts.All {
+		if installed == nil {
+			return fmt.Errorf("package %s not found (use --all to search the repositories)", opts.Package)
+		}
+		return writePackageDetails(os.Stdout, *installed, nil, installPath(composerPath, *installed, opts.Locked))
+	}
+
+	// --all: list every version the repositories offer, detailing the
+	// installed one or else the newest the project would accept
+	client, err := newProjectHTTPClient(filepath.Dir(composerPath), composer, cfg, logger)
+	if err != nil {
+		return err
+	}
+	versions, err := findPackageVersions(ctx, client, name, composer.Repositories, logger)
+	if err != nil {
+		return fmt.Errorf("find %s: %w", name, err)
+	}
+	shown := newestAcceptable(versions, composer)
+	if installed != nil {
+		shown = *installed
+	}
+	return writePackageDetails(os.Stdout, shown, versions, installPath(composerPath, shown, installed == nil))
+}
+
+// newestAcceptable returns the newest of versions (newest first) that meets
+// the project's minimum-stability, the most stable such version with
+// prefer-stable. Aliased dev branches sort by their alias, so the first
+// version is often a branch rather than the latest release.
+func newestAcceptable(versions []Package, composer ComposerJSON) Package {
+	candidates := versions
+	if composer.PreferStable {
+		candidates = preferStable(versions)
+	}
+	for _, pkg := range candidates {
+		if composer.MinimumStability.allows(versionStability(pkg.Version)) {
+			return pkg
+		}
+	}
+	return versions[0]
+}
+
+// showPackages reads the packages show works on: composer.lock with
+// --locked, otherwise installed.json. With --all a missing installed.json
+// is not an error.
+func showPackages(composerPath string, locked, all bool, logger *log.Logger) ([]Package, error) {
+	if locked {
+		lock, err := readLockFile(lockFilePath(composerPath))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("no %s found next to %s", lockFileName, composerPath)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return lock.allPackages(), nil
+	}
+
+	vendorDir := filepath.Join(filepath.Dir(composerPath), "vendor")
+	installed, err := readInstalledJSON(vendorDir)
+	if errors.Is(err, os.ErrNotExist) {
+		if all {
+			logger.Debug("No installed packages", "vendor_dir", vendorDir)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("no installed packages found in %s (run install first, or use --locked)", vendorDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return installed.allPackages(), nil
+}
+
+// installPath returns the package's directory in vendor/, or "" when the
+// package is not (necessarily) installed
+func installPath(composerPath string, pkg Package, notInstalled bool) string {
+	if notInstalled {
+		return ""
+	}
+	path, err := filepath.Abs(filepath.Join(filepath.Dir(composerPath), "vendor", pkg.Name))
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+func writePackageList(w io.Writer, packages []Package) error {
+	sorted := append([]Package{}, packages...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, pkg := range sorted {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", pkg.Name, pkg.Version, pkg.Description)
+	}
+	return tw.Flush()
+}
+
+// writePackageDetails prints everything known about one package. versions,
+// when given, are all versions available in the repositories, with the
+// shown one marked.
+func writePackageDetails(w io.Writer, pkg Package, versions []Package, path string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	field := func(label, value string) {
+		fmt.Fprintf(tw, "%s\t: %s\n", label, value)
+	}
+
+	field("name", pkg.Name)
+	field("descrip.", pkg.Description)
+	if versions == nil {
+		field("versions", "* "+pkg.Version)
+	} else {
+		list := make([]string, 0, len(versions))
+		for _, v := range versions {
+			if v.Version == pkg.Version {
+				list = append(list, "* "+v.Version)
+			} else {
+				list = append(list, v.Version)
+			}
+		}
+		field("versions", strings.Join(list, ", "))
+	}
+	field("type", valueOr(pkg.Type, "library"))
+	field("license", strings.Join(pkg.License, ", "))
+	if pkg.Source.URL != "" {
+		field("source", fmt.Sprintf("[%s] %s %s", pkg.Source.Type, pkg.Source.URL, pkg.Source.Reference))
+	}
+	if pkg.Dist.URL != "" {
+		dist := fmt.Sprintf("[%s] %s", pkg.Dist.Type, pkg.Dist.URL)
+		if sum := valueOr(pkg.Dist.Checksum, pkg.Dist.Shasum); sum != "" {
+			dist += " " + sum
+		}
+		field("dist", dist)
+	}
+	if path != "" {
+		field("path", path)
+	}
+	if pkg.InstallationSource != "" {
+		field("installed", "from "+pkg.InstallationSource)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	writeAutoload(w, pkg.Autoload)
+	writeLinks(w, "requires", pkg.Require)
+	writeLinks(w, "provides", pkg.Provide)
+	writeLinks(w, "replaces", pkg.Replace)
+	writeLinks(w, "conflicts", pkg.Conflict)
+	return nil
+}
+
+// writeRootPackage prints the root package from composer.json
+func writeRootPackage(w io.Writer, composer ComposerJSON) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "name\t: %s\n", valueOr(composer.Name, "__root__"))
+	fmt.Fprintf(tw, "descrip.\t: %s\n", composer.Description)
+	fmt.Fprintf(tw, "keywords\t: %s\n", strings.Join(composer.Keywords, ", "))
+	fmt.Fprintf(tw, "type\t: %s\n", valueOr(composer.Type, "project"))
+	fmt.Fprintf(tw, "license\t: %s\n", strings.Join(composer.License, ", "))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	writeAutoload(w, composer.Autoload)
+	writeLinks(w, "requires", composer.Require)
+	writeLinks(w, "requires (dev)", composer.RequireDev)
+	writeLinks(w, "provides", composer.Provide)
+	writeLinks(w, "replaces", composer.Replace)
+	writeLinks(w, "conflicts", composer.Conflict)
+	return nil
+}
+
+func writeAutoload(w io.Writer, autoload Autoload) {
+	if len(autoload.PSR4)+len(autoload.PSR0)+len(autoload.Classmap)+len(autoload.Files) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nautoload")
+	for _, section := range []struct {
+		name     string
+		prefixes map[string]StringOrArray
+	}{{"psr-4", autoload.PSR4}, {"psr-0", autoload.PSR0}} {
+		if len(section.prefixes) == 0 {
+			continue
+		}
+		fmt.Fprintln(w, section.name)
+		for _, prefix := range sortedKeys(section.prefixes) {
+			fmt.Fprintf(w, "%s => %s\n", prefix, strings.Join(section.prefixes[prefix], ", "))
+		}
+	}
+	if len(autoload.Classmap) > 0 {
+		fmt.Fprintln(w, "classmap")
+		fmt.Fprintln(w, strings.Join(autoload.Classmap, ", "))
+	}
+	if len(autoload.Files) > 0 {
+		fmt.Fprintln(w, "files")
+		fmt.Fprintln(w, strings.Join(autoload.Files, ", "))
+	}
+}
+
+func writeLinks(w io.Writer, title string, links map[string]string) {
+	if len(links) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s\n", title)
+	for _, name := range sortedKeys(links) {
+		fmt.Fprintf(w, "%s %s\n", name, links[name])
+	}
+}
+
+// writeDependencyTree renders what each root requirement, or the named
+// package, depends on. Requirements are resolved against packages,
+// including the packages that provide or replace them.
+func writeDependencyTree(w io.Writer, composer ComposerJSON, packages []Package, name string) error {
+	byName := make(map[string]Package, len(packages))
+	for _, pkg := range packages {
+		byName[pkg.Name] = pkg
+	}
+	for _, pkg := range packages {
+		for _, links := range []map[string]string{pkg.Provide, pkg.Replace} {
+			for target := range links {
+				if _, ok := byName[strings.ToLower(target)]; !ok {
+					byName[strings.ToLower(target)] = pkg
+				}
+			}
+		}
+	}
+
+	var roots []Package
+	if name != "" {
+		pkg, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("package %s not found", name)
+		}
+		roots = append(roots, pkg)
+	} else {
+		require := rootRequirements(composer)
+		for _, dep := range sortedKeys(require) {
+			if pkg, ok := byName[strings.ToLower(dep)]; ok {
+				roots = append(roots, pkg)
+			}
+		}
+	}
+
+	for _, pkg := range roots {
+		fmt.Fprintf(w, "%s %s %s\n", pkg.Name, pkg.Version, pkg.Description)
+		writeTreeChildren(w, pkg, byName, "", map[string]bool{pkg.Name: true})
+	}
+	return nil
+}
+
+func writeTreeChildren(w io.Writer, pkg Package, byName map[string]Package, indent string, path map[string]bool) {
+	deps := sortedKeys(pkg.Require)
+	for i, dep := range deps {
+		branch, next := "├──", indent+"│  "
+		if i == len(deps)-1 {
+			branch, next = "└──", indent+"   "
+		}
+
+		line := fmt.Sprintf("%s%s%s %s", indent, branch, dep, pkg.Require[dep])
+		child, ok := byName[strings.ToLower(dep)]
+		switch {
+		case !ok:
+			fmt.Fprintln(w, line)
+		case path[child.Name]:
+			fmt.Fprintln(w, line+" (circular dependency aborted here)")
+		default:
+			fmt.Fprintf(w, "%s (%s)\n", line, child.Version)
+			path[child.Name] = true
+			writeTreeChildren(w, child, byName, next, path)
+			delete(path, child.Name)
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,226 @@
+package pkgmgr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/julian-richter/PhpResolver/internal/config"
+)
+
+// Installation sources recorded in installed.json, and the auto preference
+// which picks source for dev versions and dist otherwise
+const (
+	installFromDist   = "dist"
+	installFromSource = "source"
+	installAuto       = "auto"
+)
+
+// PreferredInstall is composer.json's config.preferred-install: either one
+// method for every package or package name patterns (with * wildcards)
+// mapped to methods, where the first matching pattern wins
+type PreferredInstall []PreferredInstallRule
+
+type PreferredInstallRule struct {
+	Pattern string
+	Method  string // dist, source or auto
+}
+
+func (p *PreferredInstall) UnmarshalJSON(data []byte) error {
+	var method string
+	if err := json.Unmarshal(data, &method); err == nil {
+		if err := checkInstallMethod(method); err != nil {
+			return fmt.Errorf("config.preferred-install: %w", err)
+		}
+		*p = PreferredInstall{{Pattern: "*", Method: method}}
+		return nil
+	}
+
+	// Decode token by token, a map would lose the order of the patterns
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return fmt.Errorf("config.preferred-install must be a string or an object of package patterns")
+	}
+	var rules PreferredInstall
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("config.preferred-install: %w", err)
+		}
+		pattern, _ := tok.(string)
+		var method string
+		if err := dec.Decode(&method); err != nil {
+			return fmt.Errorf("config.preferred-install.%s must be a string", pattern)
+		}
+		if err := checkInstallMethod(method); err != nil {
+			return fmt.Errorf("config.preferred-install.%s: %w", pattern, err)
+		}
+		rules = append(rules, PreferredInstallRule{Pattern: pattern, Method: method})
+	}
+	*p = rules
+	return nil
+}
+
+func checkInstallMethod(method string) error {
+	switch method {
+	case installFromDist, installFromSource, installAuto:
+		return nil
+	default:
+		return fmt.Errorf("unknown install method %q (must be dist, source or auto)", method)
+	}
+}
+
+// method returns the preferred method for a package, defaulting to dist
+func (p PreferredInstall) method(name string) string {
+	for _, rule := range p {
+		if matchPackagePattern(rule.Pattern, name) {
+			return rule.Method
+		}
+	}
+	return installFromDist
+}
+
+// matchPackagePattern matches a package name against a pattern where *
+// stands for any run of characters, case-insensitively
+func matchPackagePattern(pattern, name string) bool {
+	expr := strings.ReplaceAll(regexp.QuoteMeta(strings.ToLower(pattern)), `\*`, ".*")
+	matched, err := regexp.MatchString("^"+expr+"$", strings.ToLower(name))
+	return err == nil && matched
+}
+
+// withInstallationSources decides for each package whether it is installed
+// from its dist archive or its git source. --prefer-source and --prefer-dist
+// override config.preferred-install; a package lacking the preferred kind
+// falls back to the other.
+func withInstallationSources(packages []Package, preferred PreferredInstall, opts InstallOptions) []Package {
+	out := make([]Package, len(packages))
+	for i, pkg := range packages {
+		method := preferred.method(pkg.Name)
+		switch {
+		case opts.PreferSource:
+			method = installFromSource
+		case opts.PreferDist:
+			method = installFromDist
+		}
+		if method == installAuto {
+			method = installFromDist
+			if versionStability(pkg.Version) == StabilityDev {
+				method = installFromSource
+			}
+		}
+
+		switch {
+		case method == installFromSource && !hasGitSource(pkg) && pkg.Dist.URL != "":
+			method = installFromDist
+		case method == installFromDist && pkg.Dist.URL == "" && hasGitSource(pkg):
+			method = installFromSource
+		}
+		pkg.InstallationSource = method
+		out[i] = pkg
+	}
+	return out
+}
+
+func hasGitSource(pkg Package) bool {
+	return pkg.Source.Type == "git" && pkg.Source.URL != ""
+}
+
+// installationSource returns how an installed package was installed;
+// installed.json written before sources were supported only has dists
+func installationSource(pkg Package) string {
+	if pkg.InstallationSource == "" {
+		return installFromDist
+	}
+	return pkg.InstallationSource
+}
+
+// splitByInstallationSource separates packages installed from dist archives
+// from those cloned from their git source
+func splitByInstallationSource(packages []Package) (dist, source []Package) {
+	for _, pkg := range packages {
+		if installationSource(pkg) == installFromSource {
+			source = append(source, pkg)
+		} else {
+			dist = append(dist, pkg)
+		}
+	}
+	return dist, source
+}
+
+// CloneSources clones each package's git source into
+// vendorDir/<vendor>/<name> and checks out the locked reference
+func CloneSources(ctx context.Context, packages []Package, vendorDir string, logger *log.Logger, cfg config.Config) error {
+	for _, pkg := range packages {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if err := cloneSource(ctx, pkg, filepath.Join(vendorDir, pkg.Name), logger, cfg); err != nil {
+			return fmt.Errorf("%s: %w", pkg.Name, err)
+		}
+	}
+	return nil
+}
+
+func cloneSource(ctx context.Context, pkg Package, destDir string, logger *log.Logger, cfg config.Config) error {
+	if !hasGitSource(pkg) {
+		return fmt.Errorf("no git source for %s", pkg.Version)
+	}
+	if err := os.MkdirAll(filepath.Dir(destDir), 0o755); err != nil {
+		return fmt.Errorf("create parent dir: %w", err)
+	}
+
+	if err := runGit(ctx, cfg, "", "clone", "--quiet", "--no-checkout", "--", pkg.Source.URL, destDir); err != nil {
+		return err
+	}
+	if pkg.Source.Reference != "" {
+		if err := runGit(ctx, cfg, destDir, "checkout", "--quiet", "--detach", pkg.Source.Reference, "--"); err != nil {
+			return err
+		}
+	} else if err := runGit(ctx, cfg, destDir, "checkout", "--quiet"); err != nil {
+		return err
+	}
+
+	logger.Info("Cloned package", "package", pkg.Name, "version", pkg.Version, "reference", pkg.Source.Reference, "to", destDir)
+	return nil
+}
+
+// gitTimeout bounds a single git command so an unreachable remote cannot
+// hang an install
+const gitTimeout = 10 * time.Minute
+
+// runGit runs a git command in dir without prompting for credentials,
+// returning git's error output on failure
+func runGit(ctx context.Context, cfg config.Config, dir string, args ...string) error {
+	_, err := gitOutput(ctx, cfg, dir, args...)
+	return err
+}
+
+// gitOutput is runGit returning the command's standard output
+func gitOutput(ctx context.Context, cfg config.Config, dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, cfg.Pkgmgr.GitBinary, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
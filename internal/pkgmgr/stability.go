@@ -0,0 +1,162 @@
+package pkgmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	stabilityModifierRE = regexp.MustCompile(`(?i)[._-]?(?:(stable|beta|b|rc|alpha|a|patch|pl|p)((?:[.-]?\d+)*)?)?([.-]?dev)?(?:\+.*)?$`)
+	stabilityFlagNameRE = regexp.MustCompile(`(?i)@(dev|alpha|beta|rc|stable)\b`)
+)
+
+// Stability is a version's stability level as used by minimum-stability and
+// @-flags. The zero value means stable.
+type Stability string
+
+const (
+	StabilityStable Stability = "stable"
+	StabilityRC     Stability = "RC"
+	StabilityBeta   Stability = "beta"
+	StabilityAlpha  Stability = "alpha"
+	StabilityDev    Stability = "dev"
+)
+
+// rank orders stabilities from most (0) to least stable, like Composer's
+// BasePackage::STABILITIES
+func (s Stability) rank() int {
+	switch s {
+	case StabilityRC:
+		return 5
+	case StabilityBeta:
+		return 10
+	case StabilityAlpha:
+		return 15
+	case StabilityDev:
+		return 20
+	default:
+		return 0
+	}
+}
+
+// allows reports whether a version of stability other is acceptable when s is
+// the least stable level allowed
+func (s Stability) allows(other Stability) bool {
+	return other.rank() <= s.rank()
+}
+
+func (s Stability) String() string {
+	if s == "" {
+		return string(StabilityStable)
+	}
+	return string(s)
+}
+
+func (s *Stability) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("minimum-stability must be a string: %w", err)
+	}
+	parsed, ok := parseStability(name)
+	if !ok {
+		return fmt.Errorf("invalid minimum-stability %q (must be stable, RC, beta, alpha or dev)", name)
+	}
+	*s = parsed
+	return nil
+}
+
+// parseStability parses a stability name case-insensitively; "" is stable
+func parseStability(name string) (Stability, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "stable":
+		return StabilityStable, true
+	case "rc":
+		return StabilityRC, true
+	case "beta":
+		return StabilityBeta, true
+	case "alpha":
+		return StabilityAlpha, true
+	case "dev":
+		return StabilityDev, true
+	default:
+		return "", false
+	}
+}
+
+// versionStability classifies a version the way Composer's
+// VersionParser::parseStability does: dev-* branches and *-dev versions are
+// dev, otherwise the alpha/beta/RC suffix decides. patch/pl suffixes are stable.
+func versionStability(version string) Stability {
+	version = strings.ToLower(strings.TrimSpace(version))
+	if i := strings.IndexByte(version, '#'); i >= 0 {
+		version = version[:i]
+	}
+	if strings.HasPrefix(version, "dev-") || strings.HasSuffix(version, "-dev") {
+		return StabilityDev
+	}
+
+	m := stabilityModifierRE.FindStringSubmatch(version)
+	if m == nil {
+		return StabilityStable
+	}
+	if m[3] != "" {
+		return StabilityDev
+	}
+	switch m[1] {
+	case "beta", "b":
+		return StabilityBeta
+	case "alpha", "a":
+		return StabilityAlpha
+	case "rc":
+		return StabilityRC
+	}
+	return StabilityStable
+}
+
+// constraintStability returns the stability a root constraint explicitly
+// allows: an @-flag ("^2.0@beta"), a dev-* branch, or an unstable version
+// named in the constraint itself ("^2.0-alpha3"). The least stable branch of
+// an OR constraint wins. Reports false when the constraint implies nothing
+// beyond minimum-stability.
+func constraintStability(constraint string) (Stability, bool) {
+	var result Stability
+	found := false
+	raise := func(s Stability) {
+		if !found || s.rank() > result.rank() {
+			result = s
+			found = true
+		}
+	}
+
+	for _, branch := range orConstraintRE.Split(strings.TrimSpace(constraint), -1) {
+		if m := stabilityFlagNameRE.FindStringSubmatch(branch); m != nil {
+			flag, _ := parseStability(m[1])
+			raise(flag)
+			continue
+		}
+		for _, atom := range andConstraintRE.Split(strings.TrimSpace(branch), -1) {
+			atom = strings.TrimLeft(atom, "<>=!^~")
+			if atom == "" || atom == "*" {
+				continue
+			}
+			if s := versionStability(atom); s != StabilityStable {
+				raise(s)
+			}
+		}
+	}
+	return result, found
+}
+
+// rootStabilityFlags extracts per-package stability overrides from the root
+// requirements. As in Composer, flags in dependencies' requirements have no effect.
+func rootStabilityFlags(require map[string]string) map[string]Stability {
+	flags := make(map[string]Stability)
+	for name, constraint := range require {
+		if s, ok := constraintStability(constraint); ok {
+			flags[strings.ToLower(name)] = s
+		}
+	}
+	return flags
+}
@@ -0,0 +1,308 @@
+package pkgmgr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	stagingDirName      = ".phpResolver-staging"
+	stagingCommittedTag = "committed"
+	stagingJournalName  = "journal.json"
+)
+
+// stagedFiles are the files in vendor/ an install rewrites besides packages,
+// relative to vendor/
+var stagedFiles = []string{
+	"autoload.php",
+	filepath.Join("composer", installedJSONName),
+	filepath.Join("composer", fileManifestName),
+	filepath.Join("composer", platformCheckFileName),
+}
+
+// vendorStage makes an install transactional. New package versions are
+// extracted below the staging directory first; committing moves them into
+// vendor/ while the replaced and removed packages and the previous
+// autoloader files are kept as backups, so a failure or interrupt at any
+// point can put every package back as it was.
+//
+// Layout of vendor/.phpResolver-staging:
+//
+//	packages/<vendor>/<name>  extracted, not yet committed
+//	backup/packages/...       packages moved out of vendor/
+//	backup/files/...          previous autoload.php, installed.json, ...
+//	journal.json              steps taken so far, saved before each step
+//	committed                 marker written once everything is in place
+type vendorStage struct {
+	vendorDir string
+	dir       string
+	logger    *log.Logger
+
+	journal []stageStep // completed steps, undone in reverse order
+}
+
+type stageStep struct {
+	Name    string `json:"name"` // package name or file relative to vendor/
+	File    bool   `json:"file,omitempty"`
+	Existed bool   `json:"existed,omitempty"` // vendor/ had something at name before the step
+	Added   bool   `json:"added,omitempty"`   // the step moves a staged package into vendor/
+}
+
+// newVendorStage prepares an empty staging directory, first recovering
+// from a run that was killed before it could commit or roll back
+func newVendorStage(vendorDir string, logger *log.Logger) (*vendorStage, error) {
+	s := &vendorStage{
+		vendorDir: vendorDir,
+		dir:       filepath.Join(vendorDir, stagingDirName),
+		logger:    logger,
+	}
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+	for _, dir := range []string{s.packagesDir(), s.backupPath(false, ""), s.backupPath(true, "")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("create staging dir: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// packagesDir is where new package versions are extracted before commit
+func (s *vendorStage) packagesDir() string {
+	return filepath.Join(s.dir, "packages")
+}
+
+func (s *vendorStage) backupPath(file bool, name string) string {
+	if file {
+		return filepath.Join(s.dir, "backup", "files", name)
+	}
+	return filepath.Join(s.dir, "backup", "packages", filepath.FromSlash(name))
+}
+
+// backupFiles keeps copies of the autoloader files before they are
+// rewritten. Files that don't exist yet are journaled too, so undoing
+// removes them again.
+func (s *vendorStage) backupFiles() error {
+	for _, name := range stagedFiles {
+		data, err := os.ReadFile(filepath.Join(s.vendorDir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			if err := s.record(stageStep{Name: name, File: true}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("back up %s: %w", name, err)
+		}
+		backup := s.backupPath(true, name)
+		if err := os.MkdirAll(filepath.Dir(backup), 0o755); err != nil {
+			return fmt.Errorf("back up %s: %w", name, err)
+		}
+		if err := os.WriteFile(backup, data, 0o644); err != nil {
+			return fmt.Errorf("back up %s: %w", name, err)
+		}
+		if err := s.record(stageStep{Name: name, File: true, Existed: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// install moves the staged version of a package into vendor/, backing up
+// the version it replaces
+func (s *vendorStage) install(name string) error {
+	existed, err := s.exists(name)
+	if err != nil {
+		return err
+	}
+	if err := s.record(stageStep{Name: name, Existed: existed, Added: true}); err != nil {
+		return err
+	}
+	if existed {
+		if err := s.moveToBackup(name); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(s.vendorDir, name)), 0o755); err != nil {
+		return fmt.Errorf("install %s: %w", name, err)
+	}
+	if err := os.Rename(filepath.Join(s.packagesDir(), filepath.FromSlash(name)), filepath.Join(s.vendorDir, name)); err != nil {
+		return fmt.Errorf("install %s: %w", name, err)
+	}
+	return nil
+}
+
+// remove moves a package out of vendor/ into the backup
+func (s *vendorStage) remove(name string) error {
+	existed, err := s.exists(name)
+	if err != nil || !existed {
+		return err
+	}
+	if err := s.record(stageStep{Name: name, Existed: true}); err != nil {
+		return err
+	}
+	return s.moveToBackup(name)
+}
+
+// exists reports whether vendor/ has something at a package's path
+func (s *vendorStage) exists(name string) (bool, error) {
+	if _, err := os.Lstat(filepath.Join(s.vendorDir, name)); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("back up %s: %w", name, err)
+	}
+	return true, nil
+}
+
+func (s *vendorStage) moveToBackup(name string) error {
+	backup := s.backupPath(false, name)
+	if err := os.MkdirAll(filepath.Dir(backup), 0o755); err != nil {
+		return fmt.Errorf("back up %s: %w", name, err)
+	}
+	if err := os.Rename(filepath.Join(s.vendorDir, name), backup); err != nil {
+		return fmt.Errorf("back up %s: %w", name, err)
+	}
+	return nil
+}
+
+// record adds a step to the journal and saves the journal before the step
+// is carried out, so recover can undo it if the process dies midway
+func (s *vendorStage) record(step stageStep) error {
+	s.journal = append(s.journal, step)
+	if err := writeJSONFile(filepath.Join(s.dir, stagingJournalName), s.journal); err != nil {
+		s.journal = s.journal[:len(s.journal)-1]
+		return fmt.Errorf("save staging journal: %w", err)
+	}
+	return nil
+}
+
+// commit marks the transaction as complete and discards the backups
+func (s *vendorStage) commit() error {
+	if err := os.WriteFile(filepath.Join(s.dir, stagingCommittedTag), nil, 0o644); err != nil {
+		return fmt.Errorf("mark staging committed: %w", err)
+	}
+	s.removeEmptyNamespaces()
+	if err := os.RemoveAll(s.dir); err != nil {
+		// Everything is in place; the next run removes the leftovers
+		s.logger.Warn("Failed to clean up staging directory", "path", s.dir, "error", err)
+	}
+	s.journal = nil
+	return nil
+}
+
+// rollback undoes every journaled step in reverse order and removes the
+// staging directory. It keeps going after errors so as much as possible is
+// restored, and does not take a context: it must also run after an interrupt.
+func (s *vendorStage) rollback() error {
+	var errs []error
+	for i := len(s.journal) - 1; i >= 0; i-- {
+		if err := s.undo(s.journal[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.removeEmptyNamespaces()
+	s.journal = nil
+
+	if len(errs) > 0 {
+		// Keep the backups around for manual recovery
+		return fmt.Errorf("roll back vendor (backups kept in %s): %w", s.dir, errors.Join(errs...))
+	}
+	if err := os.RemoveAll(s.dir); err != nil {
+		s.logger.Warn("Failed to clean up staging directory", "path", s.dir, "error", err)
+	}
+	return nil
+}
+
+// undo reverts a step, which may have been cut short: a package that was
+// not yet moved to the backup is still in place and is left alone
+func (s *vendorStage) undo(step stageStep) error {
+	current := filepath.Join(s.vendorDir, step.Name)
+	backup := s.backupPath(step.File, step.Name)
+
+	if step.File {
+		if !step.Existed {
+			if err := os.Remove(current); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("restore %s: %w", step.Name, err)
+			}
+			return nil
+		}
+		data, err := os.ReadFile(backup)
+		if err != nil {
+			return fmt.Errorf("restore %s: %w", step.Name, err)
+		}
+		if err := os.WriteFile(current, data, 0o644); err != nil {
+			return fmt.Errorf("restore %s: %w", step.Name, err)
+		}
+		return nil
+	}
+
+	backedUp := false
+	if step.Existed {
+		if _, err := os.Lstat(backup); err == nil {
+			backedUp = true
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("restore %s: %w", step.Name, err)
+		}
+	}
+	if step.Added && (!step.Existed || backedUp) {
+		if err := os.RemoveAll(current); err != nil {
+			return fmt.Errorf("restore %s: %w", step.Name, err)
+		}
+	}
+	if backedUp {
+		if err := os.Rename(backup, current); err != nil {
+			return fmt.Errorf("restore %s: %w", step.Name, err)
+		}
+	}
+	return nil
+}
+
+// removeEmptyNamespaces drops vendor/<vendor> directories whose last
+// package was removed or rolled back
+func (s *vendorStage) removeEmptyNamespaces() {
+	for _, step := range s.journal {
+		if step.File {
+			continue
+		}
+		namespace := filepath.Dir(filepath.Join(s.vendorDir, step.Name))
+		if entries, err := os.ReadDir(namespace); err == nil && len(entries) == 0 {
+			_ = os.Remove(namespace)
+		}
+	}
+}
+
+// recover cleans up after a run that was killed while staging. If it had
+// committed, only the leftovers are removed; otherwise every step in the
+// saved journal is undone: backed up packages and files are put back, and
+// packages and files the run added are removed.
+func (s *vendorStage) recover() error {
+	if _, err := os.Stat(s.dir); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if _, err := os.Stat(filepath.Join(s.dir, stagingCommittedTag)); err != nil {
+		s.logger.Warn("Found an interrupted install, restoring the previous vendor state", "path", s.dir)
+
+		// Without a journal the run never got to change vendor/
+		data, err := os.ReadFile(filepath.Join(s.dir, stagingJournalName))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("read staging journal: %w", err)
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &s.journal); err != nil {
+				return fmt.Errorf("parse staging journal %s: %w", filepath.Join(s.dir, stagingJournalName), err)
+			}
+		}
+		return s.rollback()
+	}
+
+	if err := os.RemoveAll(s.dir); err != nil {
+		return fmt.Errorf("remove leftover staging dir: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package pkgmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/log"
+	"github.com/julian-richter/PhpResolver/internal/config"
+)
+
+// StatusOptions holds command-line options for status
+type StatusOptions struct {
+	Verbose bool // -v/--verbose: list the changed files of each package
+}
+
+// RunStatus lists installed packages with local modifications. Source
+// installs are checked with git status, dist installs against the file
+// hashes recorded when they were installed. Returns an error if any package
+// was modified.
+func RunStatus(ctx context.Context, logger *log.Logger, cfg config.Config, opts StatusOptions) error {
+	composerPath, err := FindComposerJSON(".")
+	if err != nil {
+		return fmt.Errorf("find composer.json: %w", err)
+	}
+
+	vendorDir := filepath.Join(filepath.Dir(composerPath), "vendor")
+	installed, err := readInstalledJSON(vendorDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no installed packages found in %s (run install first)", vendorDir)
+	}
+	if err != nil {
+		return err
+	}
+	manifest, err := readFileManifest(vendorDir)
+	if err != nil {
+		return err
+	}
+
+	modified := make(map[string]localChanges)
+	var names, unknown []string
+	for _, pkg := range installed.allPackages() {
+		if !dirExists(filepath.Join(vendorDir, pkg.Name)) {
+			continue
+		}
+		changes, known, err := detectChanges(ctx, pkg, vendorDir, manifest, cfg)
+		if err != nil {
+			return fmt.Errorf("check %s for local changes: %w", pkg.Name, err)
+		}
+		switch {
+		case !known:
+			unknown = append(unknown, pkg.Name)
+		case !changes.empty():
+			modified[pkg.Name] = changes
+			names = append(names, pkg.Name)
+		}
+	}
+
+	if len(unknown) > 0 {
+		// Unchanged packages are skipped by install, so only a missing
+		// directory makes it extract the package again and record its hashes
+		logger.Warn("No file hashes recorded for some packages; to detect local changes, delete vendor/<package> (discarding any edits) and run install", "packages", unknown)
+	}
+	if len(names) == 0 {
+		logger.Info("No local changes")
+		return nil
+	}
+
+	if err := writeStatus(os.Stdout, vendorDir, names, modified, opts.Verbose); err != nil {
+		return fmt.Errorf("write status: %w", err)
+	}
+	return fmt.Errorf("%d installed package(s) have local changes", len(names))
+}
+
+func writeStatus(w io.Writer, vendorDir string, names []string, modified map[string]localChanges, verbose bool) error {
+	if _, err := fmt.Fprintln(w, "You have changes in the following dependencies:"); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, err := fmt.Fprintln(w, filepath.Join(vendorDir, name)); err != nil {
+			return err
+		}
+		if !verbose {
+			continue
+		}
+		for _, line := range modified[name].lines() {
+			if _, err := fmt.Fprintln(w, "    "+line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
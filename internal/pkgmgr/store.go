@@ -0,0 +1,463 @@
+package pkgmgr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/julian-richter/PhpResolver/internal/config"
+)
+
+// errReflinkUnsupported is returned by cloneFile where copy-on-write clones
+// are not available
+var errReflinkUnsupported = errors.New("reflinks not supported")
+
+// StoreDir returns the content-addressed package store used by the link
+// install mode, creating it if needed. Each entry is an unpacked dist
+// archive keyed by the archive's checksum:
+// ~/.phpResolver/store/<algo>/<first two hex digits>/<hex>/
+func StoreDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("get user home dir: %w", err)
+	}
+	storeDir := filepath.Join(home, ".phpResolver", "store")
+	if err := os.MkdirAll(storeDir, 0o755); err != nil {
+		return "", fmt.Errorf("create store dir: %w", err)
+	}
+	return storeDir, nil
+}
+
+// storeManifestSuffix names the file next to each store entry that records
+// the size and modification time of every file in it
+const storeManifestSuffix = ".files.json"
+
+// storeFile is the recorded state of one file in a store entry
+type storeFile struct {
+	Size    int64 `json:"size"`
+	ModTime int64 `json:"mtime"` // Unix nanoseconds
+}
+
+func storeManifestPath(entry string) string {
+	return entry + storeManifestSuffix
+}
+
+func storeLockPath(entry string) string {
+	return entry + ".lock"
+}
+
+// linkFromStore populates destDir with a package's files from the shared
+// store, unpacking the cached archive into the store first if this is the
+// first project to install it. Files are reflinked where the filesystem
+// supports it, hard linked otherwise and copied as a last resort.
+//
+// Hard linked files are shared with every other project using the same
+// package version. Store files are read-only so they aren't edited through
+// vendor/ by accident; an entry that was modified anyway is rebuilt from the
+// archive before linking.
+func linkFromStore(ctx context.Context, pkg Package, cachePath, destDir string, logger *log.Logger, cfg config.Config) error {
+	entry, lock, err := storeEntry(ctx, cachePath, logger, cfg)
+	if err != nil {
+		return fmt.Errorf("prepare store entry: %w", err)
+	}
+	defer lock.Release()
+
+	var l fileLinker
+	if err := l.linkTree(ctx, entry, destDir); err != nil {
+		return fmt.Errorf("link from store: %w", err)
+	}
+	logger.Debug("Linked package from store", "package", pkg.Name, "store", entry, "reflinked", l.reflinked, "hardlinked", l.hardlinked, "copied", l.copied)
+	return nil
+}
+
+// storeEntry returns the store directory holding the unpacked archive,
+// creating it, or rebuilding it if its files changed since it was created.
+// The returned lock on the entry must be held while linking from it.
+func storeEntry(ctx context.Context, cachePath string, logger *log.Logger, cfg config.Config) (string, *fileLock, error) {
+	storeDir, err := StoreDir()
+	if err != nil {
+		return "", nil, err
+	}
+
+	sum, err := archiveChecksum(cachePath)
+	if err != nil {
+		return "", nil, err
+	}
+	entry := filepath.Join(storeDir, sum.Algo, sum.Hex[:2], sum.Hex)
+	if err := os.MkdirAll(filepath.Dir(entry), 0o755); err != nil {
+		return "", nil, fmt.Errorf("create store dir: %w", err)
+	}
+
+	lock, err := acquireFileLock(ctx, storeLockPath(entry), true, lockTimeout(cfg), logger)
+	if err != nil {
+		return "", nil, fmt.Errorf("lock store entry: %w", err)
+	}
+
+	if dirExists(entry) {
+		err := verifyStoreEntry(entry)
+		if err == nil {
+			touchCacheEntry(storeManifestPath(entry))
+			return entry, lock, nil
+		}
+		logger.Warn("Store entry was modified, rebuilding it from the archive", "store", entry, "reason", err)
+	}
+	if err := buildStoreEntry(ctx, cachePath, entry, logger, cfg); err != nil {
+		lock.Release()
+		return "", nil, err
+	}
+	return entry, lock, nil
+}
+
+// buildStoreEntry unpacks the archive to a temporary directory, makes its
+// files read-only, records them and renames it into place, replacing any
+// existing (modified) entry. The caller holds the entry's lock.
+func buildStoreEntry(ctx context.Context, cachePath, entry string, logger *log.Logger, cfg config.Config) error {
+	tempDir, err := os.MkdirTemp(filepath.Dir(entry), filepath.Base(entry)+".tmp")
+	if err != nil {
+		return fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir) // No-op once renamed
+
+	if err := unpackArchive(ctx, cachePath, tempDir, logger, cfg); err != nil {
+		return err
+	}
+	files, err := sealStoreFiles(ctx, tempDir)
+	if err != nil {
+		return fmt.Errorf("seal store entry: %w", err)
+	}
+	if err := writeJSONFile(storeManifestPath(entry), files); err != nil {
+		return fmt.Errorf("record store entry: %w", err)
+	}
+
+	if err := os.RemoveAll(entry); err != nil {
+		return fmt.Errorf("remove modified store entry: %w", err)
+	}
+	if err := os.Rename(tempDir, entry); err != nil {
+		return fmt.Errorf("commit store entry: %w", err)
+	}
+	return nil
+}
+
+// sealStoreFiles makes every file below dir read-only and returns their
+// sizes and modification times, keyed by slash-separated path
+func sealStoreFiles(ctx context.Context, dir string) (map[string]storeFile, error) {
+	files := make(map[string]storeFile)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.Chmod(path, info.Mode().Perm()&^0o222); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = storeFile{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+		return nil
+	})
+	return files, err
+}
+
+// verifyStoreEntry compares an entry's files with those recorded when it was
+// created. Writing to a file, hard linked or not, changes its modification
+// time, so comparing sizes and times finds changes without re-hashing.
+func verifyStoreEntry(entry string) error {
+	data, err := os.ReadFile(storeManifestPath(entry))
+	if err != nil {
+		return fmt.Errorf("read recorded files: %w", err)
+	}
+	var recorded map[string]storeFile
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return fmt.Errorf("parse recorded files: %w", err)
+	}
+
+	seen := 0
+	err = filepath.WalkDir(entry, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(entry, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		want, ok := recorded[rel]
+		if !ok || !d.Type().IsRegular() {
+			return fmt.Errorf("unexpected file %s", rel)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() != want.Size || info.ModTime().UnixNano() != want.ModTime {
+			return fmt.Errorf("%s was changed", rel)
+		}
+		seen++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if seen != len(recorded) {
+		return fmt.Errorf("%d file(s) were deleted", len(recorded)-seen)
+	}
+	return nil
+}
+
+// listStoreEntries returns every committed store entry, sized by its files
+// and last used when a project last linked from it
+func listStoreEntries(ctx context.Context, storeDir string) ([]cacheEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(storeDir, "*", "*", "*"))
+	if err != nil {
+		return nil, fmt.Errorf("scan store dir: %w", err)
+	}
+
+	var entries []cacheEntry
+	for _, path := range paths {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		info, err := os.Lstat(path)
+		if err != nil || !info.IsDir() || strings.Contains(filepath.Base(path), ".tmp") {
+			continue
+		}
+		size, err := dirSize(path)
+		if err != nil {
+			return nil, fmt.Errorf("scan store entry %s: %w", path, err)
+		}
+
+		entry := cacheEntry{Path: path, Store: true, Size: size, LastUsed: info.ModTime()}
+		if manifestInfo, err := os.Stat(storeManifestPath(path)); err == nil {
+			entry.Size += manifestInfo.Size()
+			entry.LastUsed = manifestInfo.ModTime()
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// evictStoreEntry removes a store entry, its recorded files and its lock
+// file while holding the entry's lock. Projects that hard linked the
+// entry's files keep them.
+func evictStoreEntry(ctx context.Context, entry string, cfg config.Config, logger *log.Logger) error {
+	lock, err := acquireFileLock(ctx, storeLockPath(entry), true, lockTimeout(cfg), logger)
+	if err != nil {
+		return err
+	}
+	if err := removeStoreEntry(entry); err != nil {
+		lock.Release()
+		return err
+	}
+	return lock.ReleaseAndRemove()
+}
+
+// verifyAndEvictStoreEntry checks a store entry against its recorded files
+// under its lock and removes it if it was modified, reporting whether it was
+// removed
+func verifyAndEvictStoreEntry(ctx context.Context, entry string, cfg config.Config, logger *log.Logger) (bool, error) {
+	lock, err := acquireFileLock(ctx, storeLockPath(entry), true, lockTimeout(cfg), logger)
+	if err != nil {
+		return false, err
+	}
+
+	// The entry may have been evicted by another process while we waited
+	if !dirExists(entry) {
+		return false, lock.ReleaseAndRemove()
+	}
+
+	if err := verifyStoreEntry(entry); err != nil {
+		logger.Warn("Removing modified store entry", "path", entry, "error", err)
+		if err := removeStoreEntry(entry); err != nil {
+			lock.Release()
+			return false, fmt.Errorf("remove modified store entry: %w", err)
+		}
+		return true, lock.ReleaseAndRemove()
+	}
+
+	logger.Debug("Store entry verified", "path", entry)
+	return false, lock.Release()
+}
+
+// removeStoreEntry deletes an entry and then its recorded files, so an entry
+// is never left without them
+func removeStoreEntry(entry string) error {
+	if err := os.RemoveAll(entry); err != nil {
+		return err
+	}
+	if err := os.Remove(storeManifestPath(entry)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// removeStaleStoreTemps removes the temporary directories and files of
+// store entries whose creation was interrupted
+func removeStaleStoreTemps(storeDir string, logger *log.Logger) {
+	paths, _ := filepath.Glob(filepath.Join(storeDir, "*", "*", "*.tmp*"))
+	cutoff := time.Now().Add(-staleTempAge)
+	for _, path := range paths {
+		if info, err := os.Lstat(path); err == nil && info.ModTime().Before(cutoff) {
+			if err := os.RemoveAll(path); err == nil {
+				logger.Debug("Removed stale store temp", "path", path)
+			}
+		}
+	}
+}
+
+// archiveChecksum returns the checksum recorded for a cached archive when
+// the download left one, hashing the archive otherwise
+func archiveChecksum(cachePath string) (Checksum, error) {
+	if sum, err := readRecordedChecksum(cachePath); err == nil && len(sum.Hex) > 2 {
+		return sum, nil
+	}
+
+	file, err := os.Open(cachePath)
+	if err != nil {
+		return Checksum{}, fmt.Errorf("open archive: %w", err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return Checksum{}, fmt.Errorf("hash archive: %w", err)
+	}
+	return Checksum{Algo: defaultChecksumAlgo, Hex: hex.EncodeToString(hasher.Sum(nil))}, nil
+}
+
+// fileLinker imports files from the store, remembering which methods fail
+// so each is only attempted until the first failure
+type fileLinker struct {
+	noReflink  bool
+	noHardlink bool
+
+	reflinked, hardlinked, copied int
+}
+
+func (l *fileLinker) linkTree(ctx context.Context, src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case info.Mode().IsRegular():
+			return l.linkFile(path, target, info.Mode().Perm())
+		default:
+			return fmt.Errorf("unexpected file type in store: %s", path)
+		}
+	})
+}
+
+func (l *fileLinker) linkFile(src, dst string, perm os.FileMode) error {
+	// Store files are read-only; clones and copies are the project's own
+	writable := perm | 0o200
+
+	if !l.noReflink {
+		err := cloneFile(src, dst, writable)
+		if err == nil {
+			l.reflinked++
+			return nil
+		}
+		l.noReflink = true
+	}
+
+	if !l.noHardlink {
+		err := os.Link(src, dst)
+		if err == nil {
+			l.hardlinked++
+			return nil
+		}
+		// Typically the store and project are on different filesystems
+		l.noHardlink = true
+	}
+
+	if err := copyFile(src, dst, writable); err != nil {
+		return err
+	}
+	l.copied++
+	return nil
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("copy %s: %w", src, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,35 @@
+//go:build linux
+
+package pkgmgr
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl, supported by btrfs, XFS and other
+// copy-on-write filesystems
+const ficlone = 0x40049409
+
+// cloneFile creates dst as a copy-on-write clone of src
+func cloneFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", dst, err)
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ficlone, in.Fd())
+	closeErr := out.Close()
+	if errno != 0 {
+		os.Remove(dst)
+		return fmt.Errorf("%w: %v", errReflinkUnsupported, errno)
+	}
+	return closeErr
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package pkgmgr
+
+import "os"
+
+// cloneFile is only implemented on Linux; elsewhere the store falls back to
+// hard links
+func cloneFile(src, dst string, perm os.FileMode) error {
+	return errReflinkUnsupported
+}
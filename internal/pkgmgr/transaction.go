@@ -0,0 +1,142 @@
+package pkgmgr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/charmbracelet/log"
+)
+
+// operationKind is what an install run does to a single package in vendor/
+type operationKind string
+
+const (
+	opInstall   operationKind = "install"
+	opUpdate    operationKind = "update"
+	opUninstall operationKind = "uninstall"
+	opNoop      operationKind = "noop"
+)
+
+// operation is one step of a transaction. For updates From is the installed
+// package being replaced; for uninstalls Package is the installed package.
+type operation struct {
+	Kind    operationKind
+	Package Package
+	From    Package
+}
+
+// transaction is the set of operations that turns the installed packages
+// into the desired ones, ordered by package name within each kind
+type transaction []operation
+
+// planTransaction compares the desired packages with those recorded in
+// installed.json. A package counts as unchanged when its normalized version,
+// installation source and dist or source reference are the same and its
+// directory is still present in vendorDir.
+func planTransaction(installed, desired []Package, vendorDir string) transaction {
+	current := make(map[string]Package, len(installed))
+	for _, pkg := range installed {
+		current[pkg.Name] = pkg
+	}
+	wanted := make(map[string]bool, len(desired))
+
+	var tx transaction
+	for _, pkg := range desired {
+		wanted[pkg.Name] = true
+		old, ok := current[pkg.Name]
+		switch {
+		case !ok:
+			tx = append(tx, operation{Kind: opInstall, Package: pkg})
+		case !samePackage(old, pkg):
+			tx = append(tx, operation{Kind: opUpdate, Package: pkg, From: old})
+		case !dirExists(filepath.Join(vendorDir, pkg.Name)):
+			// Recorded as installed but deleted by hand; put it back
+			tx = append(tx, operation{Kind: opInstall, Package: pkg})
+		default:
+			tx = append(tx, operation{Kind: opNoop, Package: pkg, From: old})
+		}
+	}
+	for _, pkg := range installed {
+		if !wanted[pkg.Name] {
+			tx = append(tx, operation{Kind: opUninstall, Package: pkg})
+		}
+	}
+
+	sort.SliceStable(tx, func(i, j int) bool { return tx[i].Package.Name < tx[j].Package.Name })
+	return tx
+}
+
+// samePackage reports whether an installed package is the desired one,
+// installed the same way: from the same dist or the same source reference
+func samePackage(installed, desired Package) bool {
+	if normalizeVersion(installed.Version) != normalizeVersion(desired.Version) || installationSource(installed) != installationSource(desired) {
+		return false
+	}
+	if installationSource(desired) == installFromSource {
+		return installed.Source == desired.Source
+	}
+	return installed.Dist == desired.Dist
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// packages returns the packages of the operations of the given kinds
+func (tx transaction) packages(kinds ...operationKind) []Package {
+	var out []Package
+	for _, op := range tx {
+		for _, kind := range kinds {
+			if op.Kind == kind {
+				out = append(out, op.Package)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (tx transaction) count(kind operationKind) int {
+	n := 0
+	for _, op := range tx {
+		if op.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// summary describes the transaction like Composer's "Package operations" line
+func (tx transaction) summary() string {
+	installs, updates, removals := tx.count(opInstall), tx.count(opUpdate), tx.count(opUninstall)
+	if installs+updates+removals == 0 {
+		return "Nothing to install, update or remove"
+	}
+	return fmt.Sprintf("Package operations: %s, %s, %s",
+		pluralize(installs, "install"), pluralize(updates, "update"), pluralize(removals, "removal"))
+}
+
+// log reports the summary and every operation that changes vendor/
+func (tx transaction) log(logger *log.Logger) {
+	logger.Info(tx.summary())
+	for _, op := range tx {
+		switch op.Kind {
+		case opInstall:
+			logger.Info("Installing", "package", op.Package.Name, "version", op.Package.Version, "source", installationSource(op.Package))
+		case opUpdate:
+			logger.Info("Updating", "package", op.Package.Name, "from", op.From.Version, "to", op.Package.Version, "source", installationSource(op.Package))
+		case opUninstall:
+			logger.Info("Removing", "package", op.Package.Name, "version", op.Package.Version)
+		}
+	}
+}
+
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
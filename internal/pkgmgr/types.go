@@ -1,8 +1,10 @@
 package pkgmgr
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 // StringOrArray is a type that can unmarshal both a single string or an array of strings
@@ -26,6 +28,28 @@ func (s *StringOrArray) UnmarshalJSON(data []byte) error {
 	return fmt.Errorf("value must be string or array of strings")
 }
 
+// isEmptyJSONArray reports whether data is "[]", which PHP's json_encode emits
+// for empty objects in repository metadata
+func isEmptyJSONArray(data []byte) bool {
+	return string(bytes.TrimSpace(data)) == "[]"
+}
+
+// Links maps package names to version constraints (require, require-dev, ...)
+type Links map[string]string
+
+func (l *Links) UnmarshalJSON(data []byte) error {
+	if isEmptyJSONArray(data) {
+		*l = nil
+		return nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("links must be an object of package constraints: %w", err)
+	}
+	*l = m
+	return nil
+}
+
 type ComposerJSON struct {
 	Name             string            `json:"name"`
 	Description      string            `json:"description"`
@@ -34,14 +58,22 @@ type ComposerJSON struct {
 	License          StringOrArray     `json:"license"`
 	Require          map[string]string `json:"require"`
 	RequireDev       map[string]string `json:"require-dev,omitempty"`
+	Replace          map[string]string `json:"replace,omitempty"`
+	Provide          map[string]string `json:"provide,omitempty"`
+	Conflict         map[string]string `json:"conflict,omitempty"`
 	Autoload         Autoload          `json:"autoload,omitempty"`
-	MinimumStability string            `json:"minimum-stability,omitempty"`
+	MinimumStability Stability         `json:"minimum-stability,omitempty"`
 	PreferStable     bool              `json:"prefer-stable,omitempty"`
 	Config           Config            `json:"config,omitempty"`
 	Repositories     []Repository      `json:"repositories,omitempty"`
+	Scripts          Scripts           `json:"scripts,omitempty"`
 	AllowPlugins     map[string]bool   `json:"allow-plugins,omitempty"`
 }
 
+// Scripts maps script events (e.g. pre-package-uninstall) and custom script
+// names to the commands they run
+type Scripts map[string]StringOrArray
+
 type Autoload struct {
 	PSR4     map[string]StringOrArray `json:"psr-4,omitempty"`
 	PSR0     map[string]StringOrArray `json:"psr-0,omitempty"`
@@ -49,9 +81,68 @@ type Autoload struct {
 	Files    StringOrArray            `json:"files,omitempty"`
 }
 
+func (a *Autoload) UnmarshalJSON(data []byte) error {
+	if isEmptyJSONArray(data) {
+		*a = Autoload{}
+		return nil
+	}
+	type plain Autoload
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*a = Autoload(p)
+	return nil
+}
+
 type Config struct {
 	ProcessTimeout int      `json:"process-timeout,omitempty"`
 	FXPAsset       FXPAsset `json:"fxp-asset,omitempty"`
+	CAFile         string   `json:"cafile,omitempty"`
+	CAPath         string   `json:"capath,omitempty"`
+	DisableTLS     bool     `json:"disable-tls,omitempty"`
+	SecureHTTP     *bool    `json:"secure-http,omitempty"` // nil means Composer's default of true
+	SortPackages   bool     `json:"sort-packages,omitempty"`
+
+	PreferredInstall PreferredInstall `json:"preferred-install,omitempty"`
+	DiscardChanges   DiscardChanges   `json:"discard-changes,omitempty"`
+
+	Platform      PlatformOverrides `json:"platform,omitempty"`
+	PlatformCheck PlatformCheckMode `json:"platform-check,omitempty"`
+}
+
+// PlatformOverrides maps platform packages (php, ext-*, lib-*) to the version
+// the resolver should assume is installed. A false value in composer.json
+// hides the package and is stored as an empty version.
+type PlatformOverrides map[string]string
+
+func (p *PlatformOverrides) UnmarshalJSON(data []byte) error {
+	if isEmptyJSONArray(data) {
+		*p = nil
+		return nil
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("config.platform must be an object: %w", err)
+	}
+
+	overrides := make(PlatformOverrides, len(raw))
+	for name, value := range raw {
+		var version string
+		if err := json.Unmarshal(value, &version); err == nil {
+			overrides[strings.ToLower(name)] = version
+			continue
+		}
+		var enabled bool
+		if err := json.Unmarshal(value, &enabled); err == nil && !enabled {
+			overrides[strings.ToLower(name)] = ""
+			continue
+		}
+		return fmt.Errorf("config.platform.%s must be a version string or false", name)
+	}
+	*p = overrides
+	return nil
 }
 
 type FXPAsset struct {
@@ -64,10 +155,86 @@ type Repository struct {
 }
 
 type Package struct {
-	Name     string
-	Version  string
-	Dist     Dist
-	Autoload Autoload
+	Name        string
+	Version     string
+	Description string
+	Type        string // library, metapackage, composer-plugin, ...
+	License     []string
+	Dist        Dist
+	Source      Source
+	Autoload    Autoload
+	Require     map[string]string
+	Replace     map[string]string // packages this one replaces; they are never installed alongside it
+	Provide     map[string]string // (virtual) packages this one provides an implementation of
+	Conflict    map[string]string // package versions that may not be installed alongside it
+	Extra       json.RawMessage
+	Aliases     []string // other versions this package also satisfies (branch and inline aliases)
+
+	InstallationSource string // "dist" or "source" once installed; recorded in installed.json only
+}
+
+// packageMetadata is a single version entry in a Composer repository's
+// package metadata (packages/<name>.json). composer.lock and
+// vendor/composer/installed.json use the same schema for their entries.
+type packageMetadata struct {
+	Name               string          `json:"name"`
+	Version            string          `json:"version"`
+	VersionNormalized  string          `json:"version_normalized,omitempty"`
+	Description        string          `json:"description,omitempty"`
+	Type               string          `json:"type,omitempty"`
+	License            StringOrArray   `json:"license,omitempty"`
+	Dist               Dist            `json:"dist"`
+	Source             *Source         `json:"source,omitempty"`
+	Require            Links           `json:"require,omitempty"`
+	Replace            Links           `json:"replace,omitempty"`
+	Provide            Links           `json:"provide,omitempty"`
+	Conflict           Links           `json:"conflict,omitempty"`
+	Autoload           Autoload        `json:"autoload"`
+	Extra              json.RawMessage `json:"extra,omitempty"`
+	InstallPath        string          `json:"install-path,omitempty"`        // installed.json only
+	InstallationSource string          `json:"installation-source,omitempty"` // installed.json only
+}
+
+func (m packageMetadata) toPackage() Package {
+	pkg := Package{
+		Name:        strings.ToLower(m.Name),
+		Version:     m.Version,
+		Description: m.Description,
+		Type:        m.Type,
+		License:     m.License,
+		Dist:        m.Dist,
+		Autoload:    m.Autoload,
+		Require:     m.Require,
+		Replace:     m.Replace,
+		Provide:     m.Provide,
+		Conflict:    m.Conflict,
+		Extra:       m.Extra,
+
+		InstallationSource: m.InstallationSource,
+	}
+	if m.Source != nil {
+		pkg.Source = *m.Source
+	}
+	return pkg
+}
+
+func packageToMetadata(pkg Package) packageMetadata {
+	return packageMetadata{
+		Name:              pkg.Name,
+		Version:           pkg.Version,
+		VersionNormalized: normalizeVersion(pkg.Version),
+		Description:       pkg.Description,
+		Type:              pkg.Type,
+		License:           pkg.License,
+		Dist:              pkg.Dist,
+		Source:            pkg.Source.metadata(),
+		Require:           pkg.Require,
+		Replace:           pkg.Replace,
+		Provide:           pkg.Provide,
+		Conflict:          pkg.Conflict,
+		Autoload:          pkg.Autoload,
+		Extra:             pkg.Extra,
+	}
 }
 
 type Dist struct {
@@ -76,3 +243,19 @@ type Dist struct {
 	Checksum string `json:"checksum,omitempty"`
 	Shasum   string `json:"shasum,omitempty"`
 }
+
+// Source is the VCS repository a package version was built from. Only git
+// sources can be installed.
+type Source struct {
+	Type      string `json:"type"` // git, hg, svn
+	URL       string `json:"url"`
+	Reference string `json:"reference"` // commit the version points at
+}
+
+// metadata returns the source for package metadata, omitting an empty one
+func (s Source) metadata() *Source {
+	if s == (Source{}) {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,638 @@
+package pkgmgr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Composer version and constraint syntax, following Composer's VersionParser.
+// Versions normalize to four numeric parts plus an optional stability suffix
+// ("v2.0-beta2" becomes "2.0.0.0-beta2"), numbered branches to 9999999 parts
+// ("1.x-dev" becomes "1.9999999.9999999.9999999-dev") and any other branch
+// to "dev-<name>".
+
+const modifierPattern = `[._-]?(?:(stable|beta|b|rc|alpha|a|patch|pl|p)((?:[.-]?\d+)*)?)?([.-]?dev)?`
+
+var (
+	orConstraintRE  = regexp.MustCompile(`\s*\|\|?\s*`)
+	andConstraintRE = regexp.MustCompile(`\s*,\s*|\s+`)
+	stabilityFlagRE = regexp.MustCompile(`(?i)@(?:dev|alpha|beta|rc|stable)\b`)
+
+	classicalVersionRE = regexp.MustCompile(`(?i)^v?(\d{1,5})(\.\d+)?(\.\d+)?(\.\d+)?` + modifierPattern + `$`)
+	dateVersionRE      = regexp.MustCompile(`(?i)^v?(\d{4}(?:[.:-]?\d{2}){1,6}(?:[.:-]?\d{1,3}){0,2})` + modifierPattern + `$`)
+	numberedBranchRE   = regexp.MustCompile(`(?i)^v?(\d+)(\.(?:\d+|[x*]))?(\.(?:\d+|[x*]))?(\.(?:\d+|[x*]))?$`)
+	devSuffixRE        = regexp.MustCompile(`(?i)^(.*?)[.-]?dev$`)
+	buildMetadataRE    = regexp.MustCompile(`^([^,\s+]+)\+\S+$`)
+	branchReferenceRE  = regexp.MustCompile(`(?i)^(dev-[^,\s@]+?|[^,\s@]+?\.x-dev)#.+$`)
+
+	constraintVersionRE = regexp.MustCompile(`(?i)^v?(\d+)(?:\.(\d+|[x*]))?(?:\.(\d+|[x*]))?(?:\.(\d+|[x*]))?` + modifierPattern + `(?:\+\S+)?$`)
+	wildcardRE          = regexp.MustCompile(`(?i)^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:\.[x*])+$`)
+	matchAllRE          = regexp.MustCompile(`(?i)^v?[x*](?:\.[x*])*$`)
+	operatorRE          = regexp.MustCompile(`^(<>|!=|>=?|<=?|==?)?\s*(\S+)$`)
+	bareOperatorRE      = regexp.MustCompile(`^(?:<>|!=|>=?|<=?|==?|\^|~>?)$`)
+)
+
+// Ranks of stability modifiers within one version number, lowest first.
+// A bare "-dev" suffix sorts below every pre-release.
+const (
+	modifierDev = iota
+	modifierAlpha
+	modifierBeta
+	modifierRC
+	modifierNone
+	modifierPatch
+)
+
+// parsedVersion is a normalized Composer version
+type parsedVersion struct {
+	normalized string
+	branch     string // non-numeric branch name for dev-* versions, empty otherwise
+	parts      []int
+	modifier   int
+	modNum     []int
+	dev        bool
+}
+
+func (v parsedVersion) String() string { return v.normalized }
+
+// isBranch reports whether v is a dev-* branch that has no version number
+func (v parsedVersion) isBranch() bool { return v.branch != "" }
+
+// parseVersion normalizes a version string the way Composer does
+func parseVersion(version string) (parsedVersion, error) {
+	original := version
+	version = strings.TrimSpace(version)
+	if version == "" {
+		return parsedVersion{}, fmt.Errorf("invalid version %q: empty", original)
+	}
+
+	// Strip stability flags and build metadata, which don't take part in comparison
+	version = strings.TrimSpace(stabilityFlagRE.ReplaceAllString(version, ""))
+	if m := buildMetadataRE.FindStringSubmatch(version); m != nil {
+		version = m[1]
+	}
+
+	lower := strings.ToLower(version)
+	switch {
+	case lower == "master" || lower == "trunk" || lower == "default":
+		return branchVersion(lower), nil
+	case strings.HasPrefix(lower, "dev-"):
+		return branchVersion(version[len("dev-"):]), nil
+	}
+
+	if m := classicalVersionRE.FindStringSubmatch(version); m != nil {
+		fields := []string{m[1], "0", "0", "0"}
+		parts := []int{atoiOrZero(m[1]), 0, 0, 0}
+		for i := 2; i <= 4; i++ {
+			if m[i] != "" {
+				fields[i-1] = strings.TrimPrefix(m[i], ".")
+				parts[i-1] = atoiOrZero(fields[i-1])
+			}
+		}
+		return numericVersion(strings.Join(fields, "."), parts, m[5], m[6], m[7] != "")
+	}
+
+	if m := dateVersionRE.FindStringSubmatch(version); m != nil {
+		fields := strings.FieldsFunc(m[1], func(r rune) bool { return r < '0' || r > '9' })
+		parts := make([]int, len(fields))
+		for i, f := range fields {
+			parts[i] = atoiOrZero(f)
+		}
+		return numericVersion(strings.Join(fields, "."), parts, m[2], m[3], m[4] != "")
+	}
+
+	// Numbered branches such as 1.x-dev or 2.1.*-dev
+	if m := devSuffixRE.FindStringSubmatch(version); m != nil {
+		if v, ok := numberedBranch(m[1]); ok {
+			return v, nil
+		}
+	}
+
+	return parsedVersion{}, fmt.Errorf("invalid version %q", original)
+}
+
+// normalizeVersion returns Composer's normalized form of version, or the
+// input unchanged if it can't be parsed
+func normalizeVersion(version string) string {
+	v, err := parseVersion(version)
+	if err != nil {
+		return version
+	}
+	return v.normalized
+}
+
+func branchVersion(name string) parsedVersion {
+	return parsedVersion{normalized: "dev-" + name, branch: name, modifier: modifierDev, dev: true}
+}
+
+func numberedBranch(name string) (parsedVersion, bool) {
+	m := numberedBranchRE.FindStringSubmatch(name)
+	if m == nil {
+		return parsedVersion{}, false
+	}
+
+	parts := make([]int, 4)
+	for i := 1; i <= 4; i++ {
+		part := strings.ToLower(strings.TrimPrefix(m[i], "."))
+		if part == "" || part == "x" || part == "*" {
+			parts[i-1] = 9999999
+		} else {
+			parts[i-1] = atoiOrZero(part)
+		}
+	}
+	return parsedVersion{
+		normalized: joinParts(parts) + "-dev",
+		parts:      parts,
+		modifier:   modifierDev,
+		dev:        true,
+	}, true
+}
+
+func numericVersion(normalized string, parts []int, modifier, modNum string, dev bool) (parsedVersion, error) {
+	v := parsedVersion{parts: parts, modifier: modifierNone, dev: dev}
+
+	switch strings.ToLower(modifier) {
+	case "", "stable":
+	case "alpha", "a":
+		v.modifier = modifierAlpha
+		normalized += "-alpha"
+	case "beta", "b":
+		v.modifier = modifierBeta
+		normalized += "-beta"
+	case "rc":
+		v.modifier = modifierRC
+		normalized += "-RC"
+	case "patch", "pl", "p":
+		v.modifier = modifierPatch
+		normalized += "-patch"
+	}
+
+	if v.modifier != modifierNone && modNum != "" {
+		num := strings.TrimLeft(modNum, ".-")
+		normalized += num
+		for _, f := range strings.FieldsFunc(num, func(r rune) bool { return r == '.' || r == '-' }) {
+			v.modNum = append(v.modNum, atoiOrZero(f))
+		}
+	}
+
+	if dev {
+		normalized += "-dev"
+		if v.modifier == modifierNone {
+			v.modifier = modifierDev
+		}
+	}
+
+	v.normalized = normalized
+	return v, nil
+}
+
+func joinParts(parts []int) string {
+	s := make([]string, len(parts))
+	for i, p := range parts {
+		s[i] = strconv.Itoa(p)
+	}
+	return strings.Join(s, ".")
+}
+
+func atoiOrZero(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// compare orders two parsed versions. Branches sort below every numbered
+// version and by name among themselves.
+func (v parsedVersion) compare(o parsedVersion) int {
+	switch {
+	case v.isBranch() && o.isBranch():
+		return strings.Compare(v.branch, o.branch)
+	case v.isBranch():
+		return -1
+	case o.isBranch():
+		return 1
+	}
+
+	if c := compareInts(v.parts, o.parts); c != 0 {
+		return c
+	}
+	if v.modifier != o.modifier {
+		if v.modifier < o.modifier {
+			return -1
+		}
+		return 1
+	}
+	if c := compareInts(v.modNum, o.modNum); c != 0 {
+		return c
+	}
+	switch {
+	case v.dev == o.dev:
+		return 0
+	case v.dev:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// compareInts compares two number sequences, treating missing trailing parts as 0
+func compareInts(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// compareVersions compares two Composer version strings and returns:
+// -1 if v1 < v2
+//
+//	0 if v1 == v2
+//	1 if v1 > v2
+//
+// Unparseable versions sort below parseable ones and by plain string
+// comparison among themselves.
+func compareVersions(v1, v2 string) int {
+	ver1, err1 := parseVersion(v1)
+	ver2, err2 := parseVersion(v2)
+
+	switch {
+	case err1 == nil && err2 == nil:
+		return ver1.compare(ver2)
+	case err1 != nil && err2 != nil:
+		return strings.Compare(v1, v2)
+	case err1 != nil:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// versionConstraint is a single comparison such as ">= 1.2.0.0-dev"
+type versionConstraint struct {
+	op      string // one of ==, !=, <, <=, >, >=
+	version parsedVersion
+}
+
+func (c versionConstraint) matches(v parsedVersion) bool {
+	// Branches can only be compared for (in)equality
+	if v.isBranch() || c.version.isBranch() {
+		same := v.normalized == c.version.normalized
+		switch c.op {
+		case "==":
+			return same
+		case "!=":
+			return !same
+		default:
+			return false
+		}
+	}
+
+	cmp := v.compare(c.version)
+	switch c.op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+func (c versionConstraint) String() string {
+	return c.op + " " + c.version.normalized
+}
+
+// constraintSet is a parsed Composer constraint: OR-ed groups of AND-ed
+// comparisons. An empty group matches every version.
+type constraintSet [][]versionConstraint
+
+func (s constraintSet) matches(v parsedVersion) bool {
+	for _, group := range s {
+		ok := true
+		for _, c := range group {
+			if !c.matches(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// constraintCache memoizes parsed constraints; resolution checks the same
+// handful of constraints against many versions
+var constraintCache sync.Map // string -> constraintSet or error
+
+// parseConstraint parses a Composer constraint string such as
+// "^1.2 || ~2.0.3, !=2.0.5", "1.0 - 2.0", "2.*" or "dev-main". Stability
+// flags are ignored here; they only affect stability filtering.
+func parseConstraint(constraint string) (constraintSet, error) {
+	if cached, ok := constraintCache.Load(constraint); ok {
+		if err, isErr := cached.(error); isErr {
+			return nil, err
+		}
+		return cached.(constraintSet), nil
+	}
+
+	set, err := parseConstraintUncached(constraint)
+	if err != nil {
+		constraintCache.Store(constraint, err)
+		return nil, err
+	}
+	constraintCache.Store(constraint, set)
+	return set, nil
+}
+
+func parseConstraintUncached(constraint string) (constraintSet, error) {
+	trimmed := strings.TrimSpace(stabilityFlagRE.ReplaceAllString(constraint, ""))
+	if trimmed == "" {
+		return constraintSet{nil}, nil
+	}
+	if version, _, ok := splitInlineAlias(trimmed); ok {
+		trimmed = version // "dev-fix as 2.3.0" requires dev-fix
+	}
+
+	var set constraintSet
+	for _, branch := range orConstraintRE.Split(trimmed, -1) {
+		var group []versionConstraint
+		for _, atom := range splitAndConstraints(branch) {
+			parsed, err := parseConstraintAtom(atom)
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint %q: %w", constraint, err)
+			}
+			group = append(group, parsed...)
+		}
+		set = append(set, group)
+	}
+	return set, nil
+}
+
+// splitAndConstraints splits an AND group on commas and whitespace, keeping
+// operators separated from their version (">= 1.0") and hyphen ranges
+// ("1.0 - 2.0") together
+func splitAndConstraints(group string) []string {
+	var tokens []string
+	for _, t := range andConstraintRE.Split(strings.TrimSpace(group), -1) {
+		if t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+
+	var atoms []string
+	for i := 0; i < len(tokens); i++ {
+		atom := tokens[i]
+		if bareOperatorRE.MatchString(atom) && i+1 < len(tokens) {
+			i++
+			atom += tokens[i]
+		}
+		if i+2 < len(tokens) && tokens[i+1] == "-" {
+			atom += " - " + tokens[i+2]
+			i += 2
+		}
+		atoms = append(atoms, atom)
+	}
+	return atoms
+}
+
+// parseConstraintAtom expands a single constraint into comparisons
+func parseConstraintAtom(atom string) ([]versionConstraint, error) {
+	if m := branchReferenceRE.FindStringSubmatch(atom); m != nil {
+		atom = m[1] // dev-main#abcdef pins a commit; the branch is what's matched
+	}
+
+	if matchAllRE.MatchString(atom) {
+		return nil, nil
+	}
+
+	if from, to, ok := strings.Cut(atom, " - "); ok {
+		return parseHyphenRange(from, to)
+	}
+
+	if rest, ok := strings.CutPrefix(atom, "^"); ok {
+		return parseCaret(rest)
+	}
+
+	if rest, ok := strings.CutPrefix(atom, "~"); ok {
+		return parseTilde(strings.TrimPrefix(rest, ">"))
+	}
+
+	if m := wildcardRE.FindStringSubmatch(atom); m != nil {
+		position := presentParts(m[1:4])
+		low := bumpVersion(m[1:4], position, 0) + "-dev"
+		high := bumpVersion(m[1:4], position, 1) + "-dev"
+		if low == "0.0.0.0-dev" {
+			return comparisons("<", high)
+		}
+		return comparisons(">=", low, "<", high)
+	}
+
+	m := operatorRE.FindStringSubmatch(atom)
+	if m == nil {
+		return nil, fmt.Errorf("could not parse %q", atom)
+	}
+	op, raw := m[1], m[2]
+
+	v, err := parseVersion(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "", "=":
+		op = "=="
+	case "<>":
+		op = "!="
+	case "<", ">=":
+		// "<2.0" must exclude 2.0's pre-releases and ">=2.0" include them
+		if !v.isBranch() && !v.dev && v.modifier == modifierNone {
+			v, _ = parseVersion(v.normalized + "-dev")
+		}
+	}
+	return []versionConstraint{{op: op, version: v}}, nil
+}
+
+// parseCaret expands ^X.Y.Z to >=X.Y.Z <(X+1).0.0, treating leading zeros as
+// the significant part (^0.3 means >=0.3 <0.4)
+func parseCaret(version string) ([]versionConstraint, error) {
+	m := constraintVersionRE.FindStringSubmatch(version)
+	if m == nil {
+		return nil, fmt.Errorf("could not parse caret constraint ^%s", version)
+	}
+
+	position := 3
+	switch {
+	case m[1] != "0" || m[2] == "":
+		position = 1
+	case m[2] != "0" || m[3] == "":
+		position = 2
+	}
+
+	low := lowerBoundVersion(version, m)
+	high := bumpVersion(m[1:5], position, 1) + "-dev"
+	return comparisons(">=", low, "<", high)
+}
+
+// parseTilde expands ~X.Y to >=X.Y <(X+1).0 and ~X.Y.Z to >=X.Y.Z <X.(Y+1)
+func parseTilde(version string) ([]versionConstraint, error) {
+	m := constraintVersionRE.FindStringSubmatch(version)
+	if m == nil {
+		return nil, fmt.Errorf("could not parse tilde constraint ~%s", version)
+	}
+
+	position := presentParts(m[1:5])
+	low := lowerBoundVersion(version, m)
+	high := bumpVersion(m[1:5], max(1, position-1), 1) + "-dev"
+	return comparisons(">=", low, "<", high)
+}
+
+// parseHyphenRange expands "A - B". A partial upper bound includes the whole
+// series ("1.0 - 2" means <3.0), a complete one is inclusive.
+func parseHyphenRange(from, to string) ([]versionConstraint, error) {
+	fm := constraintVersionRE.FindStringSubmatch(from)
+	tm := constraintVersionRE.FindStringSubmatch(to)
+	if fm == nil || tm == nil {
+		return nil, fmt.Errorf("could not parse range %s - %s", from, to)
+	}
+
+	low := lowerBoundVersion(from, fm)
+	if (tm[2] != "" && tm[3] != "") || tm[5] != "" || tm[7] != "" {
+		high, err := parseVersion(to)
+		if err != nil {
+			return nil, err
+		}
+		return comparisons(">=", low, "<=", high.normalized)
+	}
+
+	position := 2
+	if tm[2] == "" {
+		position = 1
+	}
+	return comparisons(">=", low, "<", bumpVersion(tm[1:5], position, 1)+"-dev")
+}
+
+// lowerBoundVersion returns the lower bound of a caret/tilde/range: the
+// version itself, extended to include its pre-releases unless it names a stability
+func lowerBoundVersion(raw string, m []string) string {
+	if m[5] != "" || m[7] != "" {
+		return raw
+	}
+	return bumpVersion(m[1:5], 4, 0) + "-dev"
+}
+
+// presentParts counts the leading numeric parts given in a constraint version
+func presentParts(parts []string) int {
+	n := 0
+	for _, p := range parts {
+		if p == "" || p == "x" || p == "X" || p == "*" {
+			break
+		}
+		n++
+	}
+	return max(n, 1)
+}
+
+// bumpVersion builds a four-part version from the given parts, zeroing
+// everything after position (1-based) and adding increment at position
+func bumpVersion(parts []string, position, increment int) string {
+	out := make([]int, 4)
+	for i := 0; i < 4; i++ {
+		if i < len(parts) {
+			out[i] = atoiOrZero(parts[i])
+		}
+		switch {
+		case i+1 > position:
+			out[i] = 0
+		case i+1 == position:
+			out[i] += increment
+		}
+	}
+	return joinParts(out)
+}
+
+// comparisons builds constraints from alternating operator/version arguments
+func comparisons(pairs ...string) ([]versionConstraint, error) {
+	var out []versionConstraint
+	for i := 0; i+1 < len(pairs); i += 2 {
+		v, err := parseVersion(pairs[i+1])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, versionConstraint{op: pairs[i], version: v})
+	}
+	return out, nil
+}
+
+// constraintsIntersect reports whether some version could satisfy both
+// constraints. It probes the boundary versions of both constraints (and 0),
+// which is exact for the version sets replace/provide/conflict links use in
+// practice but can miss intersections of two purely exclusive ranges.
+func constraintsIntersect(a, b string) bool {
+	setA, err := parseConstraint(a)
+	if err != nil {
+		return false
+	}
+	setB, err := parseConstraint(b)
+	if err != nil {
+		return false
+	}
+
+	zero, _ := parseVersion("0.0.0.0-dev")
+	probes := []parsedVersion{zero}
+	for _, set := range []constraintSet{setA, setB} {
+		for _, group := range set {
+			if len(group) == 0 {
+				return true // One side matches everything
+			}
+			for _, c := range group {
+				probes = append(probes, c.version)
+			}
+		}
+	}
+
+	for _, probe := range probes {
+		if setA.matches(probe) && setB.matches(probe) {
+			return true
+		}
+	}
+	return false
+}
+
+// versionSatisfies reports whether version matches a Composer constraint.
+// Unparseable versions or constraints never match.
+func versionSatisfies(version, constraint string) bool {
+	set, err := parseConstraint(constraint)
+	if err != nil {
+		return false
+	}
+	v, err := parseVersion(version)
+	if err != nil {
+		return false
+	}
+	return set.matches(v)
+}
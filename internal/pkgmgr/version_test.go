@@ -0,0 +1,178 @@
+package pkgmgr
+
+import "testing"
+
+func TestNormalizeVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{"v2.10.1", "2.10.1.0"},
+		{"2.10.1", "2.10.1.0"},
+		{"1.0.0.0", "1.0.0.0"},
+		{"1.0", "1.0.0.0"},
+		{"2.0-beta2", "2.0.0.0-beta2"},
+		{"v2.0-beta2", "2.0.0.0-beta2"},
+		{"2.0.0-RC1", "2.0.0.0-RC1"},
+		{"1.0.0-alpha", "1.0.0.0-alpha"},
+		{"1.0.0-dev", "1.0.0.0-dev"},
+		{"dev-main", "dev-main"},
+		{"dev-feature/foo", "dev-feature/foo"},
+		{"1.x-dev", "1.9999999.9999999.9999999-dev"},
+		{"2.3.x-dev", "2.3.9999999.9999999-dev"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			parsed, err := parseVersion(tt.version)
+			if err != nil {
+				t.Fatalf("parseVersion(%q) failed: %v", tt.version, err)
+			}
+			if got := parsed.String(); got != tt.want {
+				t.Errorf("parseVersion(%q) = %q, want %q", tt.version, got, tt.want)
+			}
+			if got := normalizeVersion(tt.version); got != tt.want {
+				t.Errorf("normalizeVersion(%q) = %q, want %q", tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVersionInvalid(t *testing.T) {
+	for _, version := range []string{"", "foo", "1.0.0.0.0", "1.x", "3.x"} {
+		if _, err := parseVersion(version); err == nil {
+			t.Errorf("parseVersion(%q) succeeded, want an error", version)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		v1, v2 string
+		want   int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"v1.0.0", "1.0", 0},
+		{"1.0.0", "1.0.1", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"2.0.0", "10.0.0", -1},
+		{"1.0.0.1", "1.0.0", 1},
+
+		// Pre-releases sort below the release and by stability among themselves
+		{"2.0.0-beta2", "2.0.0", -1},
+		{"2.0.0", "2.0.0-RC1", 1},
+		{"2.0.0-alpha1", "2.0.0-beta1", -1},
+		{"2.0.0-beta1", "2.0.0-beta2", -1},
+		{"2.0.0-beta2", "2.0.0-RC1", -1},
+		{"2.0.0-dev", "2.0.0-alpha1", -1},
+		{"2.0.0-RC1", "1.9.9", 1},
+
+		// Branches sort below numbered versions
+		{"dev-main", "0.0.1", -1},
+		{"1.x-dev", "1.99.0", 1},
+		{"1.x-dev", "2.0.0", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.v1+" vs "+tt.v2, func(t *testing.T) {
+			if got := compareVersions(tt.v1, tt.v2); got != tt.want {
+				t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.v1, tt.v2, got, tt.want)
+			}
+			if got := compareVersions(tt.v2, tt.v1); got != -tt.want {
+				t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.v2, tt.v1, got, -tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionSatisfies(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		// Caret: the leftmost non-zero part is significant
+		{"^1.2", "1.2.0", true},
+		{"^1.2", "1.9.9", true},
+		{"^1.2", "2.0.0", false},
+		{"^1.2", "1.1.9", false},
+		{"^0.3", "0.3.5", true},
+		{"^0.3", "0.4.0", false},
+		{"^0.0.3", "0.0.3", true},
+		{"^0.0.3", "0.0.4", false},
+
+		// Tilde: the last given part may increase
+		{"~1.2", "1.2.0", true},
+		{"~1.2", "1.9.0", true},
+		{"~1.2", "2.0.0", false},
+		{"~1.2.3", "1.2.3", true},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"~1.2.3", "1.2.2", false},
+
+		// Hyphen ranges: a partial upper bound includes the whole series
+		{"1.0 - 2.0", "1.0.0", true},
+		{"1.0 - 2.0", "2.0.0", true},
+		{"1.0 - 2.0", "2.0.9", true},
+		{"1.0 - 2.0", "2.1.0", false},
+		{"1.0.0 - 2.0.0", "2.0.0", true},
+		{"1.0.0 - 2.0.0", "2.0.1", false},
+		{"1.0 - 2", "2.9.9", true},
+		{"1.0 - 2", "3.0.0", false},
+		{"1.0 - 2.0", "0.9.9", false},
+
+		// Wildcards
+		{"*", "0.0.1", true},
+		{"*", "dev-main", true},
+		{"2.*", "2.5.0", true},
+		{"2.*", "3.0.0", false},
+		{"2.1.*", "2.1.9", true},
+		{"2.1.*", "2.2.0", false},
+
+		// OR and AND groups
+		{"^1.0 || ^2.0", "1.5.0", true},
+		{"^1.0 || ^2.0", "2.5.0", true},
+		{"^1.0 || ^2.0", "3.0.0", false},
+		{"^1.0|^3.0", "3.1.0", true},
+		{">=1.0, <1.5", "1.4.9", true},
+		{">=1.0 <1.5", "1.5.0", false},
+		{"^2.0, !=2.0.5", "2.0.5", false},
+		{"^2.0, !=2.0.5", "2.0.6", true},
+
+		// Stability flags don't change which versions match
+		{"^1.0@dev", "1.2.0", true},
+		{"^1.0@dev", "2.0.0", false},
+		{"1.2.0@beta", "1.2.0", true},
+		{"dev-main@dev", "dev-main", true},
+		{"@dev", "1.0.0", true},
+
+		// An upper bound excludes the pre-releases of that version
+		{"<2.0", "2.0-beta", false},
+		{"<2.0", "2.0.0-RC1", false},
+		{"<2.0", "1.9.9", true},
+		{"<=2.0", "2.0.0", true},
+		{">2.0", "2.0.1-beta1", true},
+
+		// Branches only match themselves
+		{"dev-main", "dev-main", true},
+		{"dev-main", "dev-develop", false},
+		{"dev-main", "1.0.0", false},
+
+		// Unparseable constraints and versions never match
+		{"^foo", "1.0.0", false},
+		{"^1.0", "not-a-version", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint+" "+tt.version, func(t *testing.T) {
+			if got := versionSatisfies(tt.version, tt.constraint); got != tt.want {
+				t.Errorf("versionSatisfies(%q, %q) = %v, want %v", tt.version, tt.constraint, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConstraintInvalid(t *testing.T) {
+	for _, constraint := range []string{"^foo", ">=", "1.0 -", "~"} {
+		if _, err := parseConstraint(constraint); err == nil {
+			t.Errorf("parseConstraint(%q) succeeded, want an error", constraint)
+		}
+	}
+}
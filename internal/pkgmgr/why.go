@@ -0,0 +1,335 @@
+package pkgmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/log"
+	"github.com/julian-richter/PhpResolver/internal/config"
+)
+
+// WhyOptions holds command-line options for why and why-not
+type WhyOptions struct {
+	Package   string // vendor/name to explain
+	Version   string // why-not only: the version that cannot be installed
+	Recursive bool   // --recursive: every chain of dependents up to the root
+	Tree      bool   // --tree: the chains as an inverted tree
+}
+
+// dependencyLink is one package's requirement on (or conflict with) another
+type dependencyLink struct {
+	From       string // package name, or rootRequirerName
+	Version    string // version of From, empty for the root
+	Kind       string // requires, requires (dev) or conflicts
+	Target     string
+	Constraint string
+}
+
+// dependencyGraph is the installed (or locked) packages and the root with
+// their links indexed by target
+type dependencyGraph struct {
+	rootLabel string
+	packages  map[string]Package
+	incoming  map[string][]dependencyLink // requirements per target name
+	conflicts map[string][]dependencyLink // conflicts per target name
+}
+
+// RunWhy shows which packages require a package: the direct dependents, or
+// with --recursive or --tree every chain of dependents up to the root
+func RunWhy(ctx context.Context, logger *log.Logger, cfg config.Config, opts WhyOptions) error {
+	graph, err := loadDependencyGraph(logger)
+	if err != nil {
+		return err
+	}
+	name := strings.ToLower(opts.Package)
+
+	links := graph.dependents(name)
+	if len(links) == 0 {
+		logger.Info("No installed package depends on this package", "package", name)
+		return nil
+	}
+
+	switch {
+	case opts.Tree:
+		writeDependentsTree(os.Stdout, graph, name)
+		return nil
+	case opts.Recursive:
+		return writeDependencyChains(os.Stdout, graph, name)
+	default:
+		return writeDependencyLinks(os.Stdout, graph, links)
+	}
+}
+
+// RunWhyNot shows which installed packages' requirements or conflicts
+// prevent installing the given version of a package. With --recursive or
+// --tree it also shows what requires each of those packages.
+func RunWhyNot(ctx context.Context, logger *log.Logger, cfg config.Config, opts WhyOptions) error {
+	if opts.Version == "" {
+		return fmt.Errorf("why-not needs a version, e.g. vendor/name 3.0")
+	}
+	if _, err := parseVersion(opts.Version); err != nil {
+		return fmt.Errorf("why-not needs a valid version, e.g. 3.0, 3.0.0-beta1 or dev-main: %w", err)
+	}
+	graph, err := loadDependencyGraph(logger)
+	if err != nil {
+		return err
+	}
+	name := strings.ToLower(opts.Package)
+
+	var blockers []dependencyLink
+	for _, link := range graph.incoming[name] {
+		if !versionSatisfies(opts.Version, link.Constraint) {
+			blockers = append(blockers, link)
+		}
+	}
+	for _, link := range graph.conflicts[name] {
+		if versionSatisfies(opts.Version, link.Constraint) {
+			blockers = append(blockers, link)
+		}
+	}
+	if len(blockers) == 0 {
+		logger.Info("No installed package prevents this version", "package", name, "version", opts.Version)
+		return nil
+	}
+	sortDependencyLinks(blockers)
+
+	if err := writeDependencyLinks(os.Stdout, graph, blockers); err != nil {
+		return err
+	}
+	if !opts.Recursive && !opts.Tree {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for _, link := range blockers {
+		if link.From == rootRequirerName || seen[link.From] {
+			continue
+		}
+		seen[link.From] = true
+		fmt.Fprintln(os.Stdout)
+		if opts.Tree {
+			writeDependentsTree(os.Stdout, graph, link.From)
+		} else if err := writeDependencyChains(os.Stdout, graph, link.From); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadDependencyGraph builds the graph from installed.json, or from
+// composer.lock when nothing is installed
+func loadDependencyGraph(logger *log.Logger) (*dependencyGraph, error) {
+	composerPath, err := FindComposerJSON(".")
+	if err != nil {
+		return nil, fmt.Errorf("find composer.json: %w", err)
+	}
+	composer, err := ParseComposerJSON(composerPath)
+	if err != nil {
+		return nil, fmt.Errorf("parse composer.json: %w", err)
+	}
+
+	var packages []Package
+	installed, err := readInstalledJSON(filepath.Join(filepath.Dir(composerPath), "vendor"))
+	switch {
+	case err == nil:
+		packages = installed.allPackages()
+	case errors.Is(err, os.ErrNotExist):
+		lock, lockErr := readLockFile(lockFilePath(composerPath))
+		if errors.Is(lockErr, os.ErrNotExist) {
+			return nil, fmt.Errorf("no installed packages and no %s found (run install or update first)", lockFileName)
+		}
+		if lockErr != nil {
+			return nil, lockErr
+		}
+		logger.Debug("Nothing installed, using the lock file")
+		packages = lock.allPackages()
+	default:
+		return nil, err
+	}
+
+	return newDependencyGraph(composer, packages), nil
+}
+
+func newDependencyGraph(composer ComposerJSON, packages []Package) *dependencyGraph {
+	g := &dependencyGraph{
+		rootLabel: valueOr(composer.Name, "__root__"),
+		packages:  make(map[string]Package, len(packages)),
+		incoming:  make(map[string][]dependencyLink),
+		conflicts: make(map[string][]dependencyLink),
+	}
+	add := func(index map[string][]dependencyLink, from, version, kind string, links map[string]string) {
+		for target, constraint := range links {
+			if strings.TrimSpace(constraint) == "self.version" {
+				constraint = version
+			}
+			target = strings.ToLower(target)
+			index[target] = append(index[target], dependencyLink{From: from, Version: version, Kind: kind, Target: target, Constraint: constraint})
+		}
+	}
+
+	add(g.incoming, rootRequirerName, "", "requires", composer.Require)
+	add(g.incoming, rootRequirerName, "", "requires (dev)", composer.RequireDev)
+	add(g.conflicts, rootRequirerName, "", "conflicts", composer.Conflict)
+	for _, pkg := range packages {
+		g.packages[pkg.Name] = pkg
+		add(g.incoming, pkg.Name, pkg.Version, "requires", pkg.Require)
+		add(g.conflicts, pkg.Name, pkg.Version, "conflicts", pkg.Conflict)
+	}
+	for _, links := range g.incoming {
+		sortDependencyLinks(links)
+	}
+	for _, links := range g.conflicts {
+		sortDependencyLinks(links)
+	}
+	return g
+}
+
+// dependents returns the requirements on a package, including those on the
+// names it provides or replaces
+func (g *dependencyGraph) dependents(name string) []dependencyLink {
+	links := append([]dependencyLink{}, g.incoming[name]...)
+	if pkg, ok := g.packages[name]; ok {
+		for _, aliases := range []map[string]string{pkg.Provide, pkg.Replace} {
+			for alias := range aliases {
+				if alias = strings.ToLower(alias); alias != name {
+					links = append(links, g.incoming[alias]...)
+				}
+			}
+		}
+	}
+	sortDependencyLinks(links)
+	return links
+}
+
+func (g *dependencyGraph) label(name string) string {
+	if name == rootRequirerName {
+		return g.rootLabel
+	}
+	return name
+}
+
+func sortDependencyLinks(links []dependencyLink) {
+	sort.SliceStable(links, func(i, j int) bool {
+		// The root first, then by package name
+		if (links[i].From == rootRequirerName) != (links[j].From == rootRequirerName) {
+			return links[i].From == rootRequirerName
+		}
+		if links[i].From != links[j].From {
+			return links[i].From < links[j].From
+		}
+		return links[i].Target < links[j].Target
+	})
+}
+
+func writeDependencyLinks(w io.Writer, g *dependencyGraph, links []dependencyLink) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	for _, link := range links {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s (%s)\n", g.label(link.From), valueOr(link.Version, "-"), link.Kind, link.Target, link.Constraint)
+	}
+	return tw.Flush()
+}
+
+// writeDependencyChains prints every path of requirements from the root to
+// name, one per line. Each path visits a package at most once, so circular
+// dependencies don't make it endless.
+func writeDependencyChains(w io.Writer, g *dependencyGraph, name string) error {
+	var chains [][]dependencyLink
+	var walk func(name string, chain []dependencyLink, visiting map[string]bool)
+	walk = func(name string, chain []dependencyLink, visiting map[string]bool) {
+		visiting[name] = true
+		defer delete(visiting, name)
+		for _, link := range g.dependents(name) {
+			if visiting[link.From] {
+				continue // Circular dependency, not a path from the root
+			}
+			next := append([]dependencyLink{link}, chain...)
+			if link.From == rootRequirerName {
+				chains = append(chains, next)
+				continue
+			}
+			walk(link.From, next, visiting)
+		}
+	}
+	walk(name, nil, map[string]bool{})
+
+	if len(chains) == 0 {
+		_, err := fmt.Fprintf(w, "%s is not required by the root package, directly or indirectly\n", name)
+		return err
+	}
+
+	sort.SliceStable(chains, func(i, j int) bool {
+		if len(chains[i]) != len(chains[j]) {
+			return len(chains[i]) < len(chains[j])
+		}
+		return chainString(g, chains[i]) < chainString(g, chains[j])
+	})
+	printed := map[string]bool{}
+	for _, chain := range chains {
+		line := chainString(g, chain)
+		if printed[line] {
+			continue
+		}
+		printed[line] = true
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func chainString(g *dependencyGraph, chain []dependencyLink) string {
+	parts := []string{g.rootLabel}
+	for _, link := range chain {
+		parts = append(parts, fmt.Sprintf("%s (%s)", link.Target, link.Constraint))
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// writeDependentsTree renders the dependents of name as an inverted tree
+// that ends at the root package. Like Composer, each package's dependents
+// are expanded only the first time it appears.
+func writeDependentsTree(w io.Writer, g *dependencyGraph, name string) {
+	if pkg, ok := g.packages[name]; ok {
+		fmt.Fprintf(w, "%s %s %s\n", pkg.Name, pkg.Version, pkg.Description)
+	} else {
+		fmt.Fprintln(w, name)
+	}
+	writeDependentsBranch(w, g, name, "", map[string]bool{name: true}, map[string]bool{name: true})
+}
+
+func writeDependentsBranch(w io.Writer, g *dependencyGraph, name, indent string, path, expanded map[string]bool) {
+	links := g.dependents(name)
+	for i, link := range links {
+		branch, next := "├──", indent+"│  "
+		if i == len(links)-1 {
+			branch, next = "└──", indent+"   "
+		}
+
+		line := fmt.Sprintf("%s%s%s", indent, branch, g.label(link.From))
+		if link.Version != "" {
+			line += " " + link.Version
+		}
+		line += fmt.Sprintf(" (%s %s %s)", link.Kind, link.Target, link.Constraint)
+		switch {
+		case link.From == rootRequirerName:
+			fmt.Fprintln(w, line)
+		case path[link.From]:
+			fmt.Fprintln(w, line+" (circular dependency aborted here)")
+		case expanded[link.From]:
+			fmt.Fprintln(w, line+" (dependents shown above)")
+		default:
+			fmt.Fprintln(w, line)
+			path[link.From] = true
+			expanded[link.From] = true
+			writeDependentsBranch(w, g, link.From, next, path, expanded)
+			delete(path, link.From)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package pkgmgr
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/julian-richter/PhpResolver/internal/config"
+)
+
+// errUnsafeArchive marks dist archives rejected before or during extraction
+var errUnsafeArchive = errors.New("unsafe archive")
+
+// ratioCheckMinSize exempts small entries from the compression ratio limit;
+// tiny files of repeated bytes legitimately compress extremely well
+const ratioCheckMinSize = 1 << 20
+
+// extractLimits bounds what a single dist archive may expand to. Zero
+// disables a limit.
+type extractLimits struct {
+	maxTotalBytes int64
+	maxFileBytes  int64
+	maxEntries    int64
+	maxRatio      int64
+}
+
+func extractLimitsFromConfig(cfg config.Config) extractLimits {
+	return extractLimits{
+		maxTotalBytes: int64(cfg.Pkgmgr.ExtractMaxTotalSizeMB) << 20,
+		maxFileBytes:  int64(cfg.Pkgmgr.ExtractMaxFileSizeMB) << 20,
+		maxEntries:    int64(cfg.Pkgmgr.ExtractMaxEntries),
+		maxRatio:      int64(cfg.Pkgmgr.ExtractMaxCompressionRatio),
+	}
+}
+
+// checkZipEntries validates an archive's central directory before anything
+// is written: entry count, declared sizes and compression ratios, and that
+// every entry is a regular file or directory with a unique relative path
+func checkZipEntries(files []*zip.File, limits extractLimits) error {
+	if limits.maxEntries > 0 && int64(len(files)) > limits.maxEntries {
+		return fmt.Errorf("%d entries exceed the limit of %d (pkgmgr.extract_max_entries): %w", len(files), limits.maxEntries, errUnsafeArchive)
+	}
+
+	seen := make(map[string]bool, len(files))
+	var total uint64
+	for _, file := range files {
+		name := file.Name
+		if strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) || (len(name) >= 2 && name[1] == ':') {
+			return fmt.Errorf("zip entry %q: absolute path: %w", name, errUnsafeArchive)
+		}
+		for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+			if part == ".." {
+				return fmt.Errorf("zip entry %q: path escapes the package directory: %w", name, errUnsafeArchive)
+			}
+		}
+
+		mode := file.Mode()
+		if mode&(os.ModeDevice|os.ModeCharDevice|os.ModeNamedPipe|os.ModeSocket|os.ModeIrregular) != 0 {
+			return fmt.Errorf("zip entry %q: special file (%s) not allowed: %w", name, mode.Type(), errUnsafeArchive)
+		}
+
+		key := path.Clean(strings.TrimSuffix(name, "/"))
+		if seen[key] {
+			return fmt.Errorf("zip entry %q: duplicate entry: %w", name, errUnsafeArchive)
+		}
+		seen[key] = true
+
+		if mode.IsDir() {
+			continue
+		}
+
+		size := file.UncompressedSize64
+		if limits.maxFileBytes > 0 && size > uint64(limits.maxFileBytes) {
+			return fmt.Errorf("zip entry %q: %d bytes exceed the %d MB per-file limit (pkgmgr.extract_max_file_size_mb): %w", name, size, limits.maxFileBytes>>20, errUnsafeArchive)
+		}
+		total += size
+		if limits.maxTotalBytes > 0 && total > uint64(limits.maxTotalBytes) {
+			return fmt.Errorf("zip entry %q: archive expands beyond the %d MB limit (pkgmgr.extract_max_total_size_mb): %w", name, limits.maxTotalBytes>>20, errUnsafeArchive)
+		}
+		if limits.maxRatio > 0 && size > ratioCheckMinSize {
+			if file.CompressedSize64 == 0 || size/file.CompressedSize64 > uint64(limits.maxRatio) {
+				return fmt.Errorf("zip entry %q: compression ratio of %d bytes from %d exceeds %d:1 (pkgmgr.extract_max_compression_ratio): %w", name, size, file.CompressedSize64, limits.maxRatio, errUnsafeArchive)
+			}
+		}
+	}
+	return nil
+}
+
+// extractBudget tracks the bytes actually written for one archive, so
+// entries whose headers understate their size are still caught
+type extractBudget struct {
+	limits  extractLimits
+	written int64
+}
+
+// copyEntry copies an entry's contents to dst, failing as soon as either
+// the per-file or the per-archive limit is exceeded
+func (b *extractBudget) copyEntry(dst io.Writer, src io.Reader, name string) error {
+	limit := int64(-1)
+	if b.limits.maxFileBytes > 0 {
+		limit = b.limits.maxFileBytes
+	}
+	if b.limits.maxTotalBytes > 0 {
+		if remaining := b.limits.maxTotalBytes - b.written; limit < 0 || remaining < limit {
+			limit = remaining
+		}
+	}
+
+	if limit < 0 {
+		n, err := io.Copy(dst, src)
+		b.written += n
+		return err
+	}
+
+	n, err := io.Copy(dst, io.LimitReader(src, limit+1))
+	b.written += n
+	if err != nil {
+		return err
+	}
+	if n > limit {
+		if b.limits.maxFileBytes > 0 && n > b.limits.maxFileBytes {
+			return fmt.Errorf("zip entry %q: exceeds the %d MB per-file limit (pkgmgr.extract_max_file_size_mb): %w", name, b.limits.maxFileBytes>>20, errUnsafeArchive)
+		}
+		return fmt.Errorf("zip entry %q: archive expands beyond the %d MB limit (pkgmgr.extract_max_total_size_mb): %w", name, b.limits.maxTotalBytes>>20, errUnsafeArchive)
+	}
+	return nil
+}